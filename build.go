@@ -6,18 +6,27 @@ This exists to allow arbitrary plugin choices to be easily built together since
 there can only be one Go runtime in a process, so only one plugin DLL built
 using 7zplugin can be used and must contain all desired plugins.
 
-The arch argument must be set to one of:
+The arch argument must be set to one or more (comma-separated) of the
+following, or "all" to build all of them:
 
-  - 64   64-bit x86      (CGO_ENABLED=1 GOOS=windows GOARCH=amd64)
-  - 32   32-bit x86_64   (CGO_ENABLED=1 GOOS=windows GOARCH=386)
-  - A64  64-bit arm      (CGO_ENABLED=1 GOOS=windows GOARCH=arm64)
+  - 64     64-bit x86      (CGO_ENABLED=1 GOOS=windows GOARCH=amd64)
+  - 32     32-bit x86_64   (CGO_ENABLED=1 GOOS=windows GOARCH=386)
+  - ARM64  64-bit arm      (CGO_ENABLED=1 GOOS=windows GOARCH=arm64)
+
+If multiple archs are specified, they are built in parallel, each in their own
+build directory, and the output of each build is prefixed with the arch. A
+summary is shown at the end.
 
 Specify environment variables for go build (CC, CXX, etc) as arguments before
-the flags.
+the flags. To only set a variable for one arch, prefix it with the arch and a
+colon (e.g., 32:CC=i686-w64-mingw32-gcc). If CC isn't set, the mingw-w64
+compiler for the arch (e.g., x86_64-w64-mingw32-gcc) is used if it is in the
+PATH.
 
 Flags are passed as-is to go build (see go help build). The only flag explicitly
 set by this package is -buildmode=c-shared. It is highly recommended to also set
--ldflags '-s -w -extldflags=-static'.
+-ldflags '-s -w -extldflags=-static'. If multiple archs are built, -o sets the
+output directory.
 
 Version information is added to the built binary:
 
@@ -35,6 +44,10 @@ the 7zplugin source dir):
 
 	CC=x86_64-w64-mingw32-gcc go run . 64 -ldflags '-s -w -extldflags=-static' -trimpath -v -x ./plugins/...
 
+Or to build all archs using the mingw-w64 compilers in the PATH:
+
+	go run . all -ldflags '-s -w -extldflags=-static' -trimpath -v ./plugins/...
+
 If using 7zplugin as a library, you can build the default plugins combined with
 your plugin like:
 
@@ -43,8 +56,46 @@ your plugin like:
 Each plugin package or file should contain an init() function which calls
 z7plugin.RegisterArc (or imports packages which do).
 
+If -reproducible is specified, the packages are sorted and deduplicated, -trimpath
+and -ldflags=-buildid= are set, -Wl,--no-insert-timestamp is added to the
+-extldflags, the FileVersion date is taken from the commit time of the git
+repository if SOURCE_DATE_EPOCH isn't set, and the GITHUB_* variables are
+ignored. The SHA-256 of each DLL is shown in the summary.
+
+To confirm existing DLLs (e.g., from a release) match the source, run the
+verify command with the same arguments used to build them:
+
+	go run . verify 64,32 -ldflags '-s -w -extldflags=-static' -v ./plugins/...
+
+This does a reproducible build into a temporary directory, and fails if the
+rebuilt DLLs don't match the files at the output paths. The Go and C compiler
+versions must be the same as the original build.
+
+After linking, the DLL is checked to ensure it has the correct machine type and
+exports all functions required by 7-Zip, and the build fails otherwise.
+
+A JSON manifest describing the registered formats, codecs and hashers (see
+z7plugin.Manifest) is saved beside each DLL with the extension replaced by
+.json. It is generated by building the plugins for and running them on the host
+with the host's Go environment (the env var overrides apply, except CC and CXX,
+which are for the DLL), so on Windows, cgo must work for the host. It is skipped
+with a warning if this fails (e.g., if the plugins only build for Windows and
+this isn't a Windows host).
+
 The built DLL file should be installed to the Formats directory beside the 7-Zip
-executable. The architecture must match that of 7-Zip.
+executable. The architecture must match that of 7-Zip. To do this
+automatically, use the install command with the 7-Zip directory. It detects the
+architecture of 7-Zip from 7z.dll/7z.exe, then installs the matching DLL out of
+the ones specified, or the default one in the current directory if none are
+specified, or builds it if packages are specified:
+
+	go run . install "C:\Program Files\7-Zip" go7zPlugin64.dll go7zPlugin32.dll
+	go run . install "C:\Program Files\7-Zip" -ldflags '-s -w -extldflags=-static' -trimpath ./plugins/...
+
+Any previously installed plugin DLLs are moved to Formats.bak since 7-Zip
+attempts to load all files in Formats. If there is already a backup, it is kept
+since it is the version from before the first install. The uninstall command
+removes the installed plugin DLLs and restores the backups.
 */
 package main
 
@@ -52,20 +103,26 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"debug/pe"
 	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
@@ -94,12 +151,40 @@ func init() {
 }
 
 func help() {
-	fmt.Printf("usage: %s arch [ENV=VALUE...] [flags] [package...]\n%s", os.Args[0], doc)
+	fmt.Printf("usage: %s [verify] arch[,arch...]|all [[ARCH:]ENV=VALUE...] [-reproducible] [flags] [package...]\n", os.Args[0])
+	fmt.Printf("       %s install 7zdir [dll...]\n", os.Args[0])
+	fmt.Printf("       %s install 7zdir [[ARCH:]ENV=VALUE...] [flags] [package...]\n", os.Args[0])
+	fmt.Printf("       %s uninstall 7zdir\n%s", os.Args[0], doc)
 	os.Exit(0)
 }
 
 var dllname = "go7zPlugin"
 
+// target is an architecture the plugin can be built for.
+type target struct {
+	Arch    string // the arch argument
+	GOARCH  string
+	Z7Arch  string // the 7-Zip platform name
+	Mingw   string // the mingw-w64 target triple
+	Machine uint16 // the PE machine type
+}
+
+var targets = []target{
+	{"64", "amd64", "x64", "x86_64-w64-mingw32", pe.IMAGE_FILE_MACHINE_AMD64},
+	{"32", "386", "x86", "i686-w64-mingw32", pe.IMAGE_FILE_MACHINE_I386},
+	{"ARM64", "arm64", "arm64", "aarch64-w64-mingw32", pe.IMAGE_FILE_MACHINE_ARM64},
+}
+
+// result is the result of building a target.
+type result struct {
+	target
+	out string
+	cmp string // the existing dll to compare against (for verify)
+	sum string // the sha256 of the built dll
+	dur time.Duration
+	err error
+}
+
 func main() {
 	var err error
 	if len(os.Args) <= 1 {
@@ -116,23 +201,55 @@ func main() {
 		}
 	}
 
+	// check for the install commands
+	switch os.Args[1] {
+	case "install":
+		if err := install(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "7zplugin: error: install: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "uninstall":
+		if err := uninstall(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "7zplugin: error: uninstall: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// check for the verify command
+	var verify bool
+	if os.Args[1] == "verify" {
+		verify = true
+		os.Args = slices.Delete(os.Args, 1, 2)
+		if len(os.Args) <= 1 {
+			help()
+		}
+	}
+
 	// expand the arch arg
-	var arch, z7arch string
-	switch arch = os.Args[1]; arch {
-	case "64":
-		os.Args[1] = "GOARCH=amd64"
-		z7arch = "x64"
-	case "32":
-		os.Args[1] = "GOARCH=386"
-		z7arch = "x86"
-	case "ARM64":
-		os.Args[1] = "GOARCH=arm64"
-		z7arch = "arm64"
-	default:
-		fmt.Fprintf(os.Stderr, "7zplugin: error: unknown arch %q\n", arch)
-		os.Exit(2)
+	var build []target
+	if os.Args[1] == "all" {
+		build = targets
+	} else {
+	arch:
+		for _, arch := range strings.Split(os.Args[1], ",") {
+			for _, t := range build {
+				if t.Arch == arch {
+					continue arch
+				}
+			}
+			for _, t := range targets {
+				if t.Arch == arch {
+					build = append(build, t)
+					continue arch
+				}
+			}
+			fmt.Fprintf(os.Stderr, "7zplugin: error: unknown arch %q\n", arch)
+			os.Exit(2)
+		}
 	}
-	os.Args = slices.Insert(os.Args, 1, "CGO_ENABLED=1", "GOOS=windows")
+	os.Args = slices.Delete(os.Args, 1, 2)
 
 	// get the current dir
 	dir, err := os.Getwd()
@@ -160,28 +277,50 @@ func main() {
 	}
 	fmt.Println()
 
-	// extract and set env vars from args
+	// extract env vars from args
 	fmt.Println("go env override")
-	var env int
+	var (
+		env     int
+		envAll  []string
+		envArch = map[string][]string{}
+	)
 	for env = 1; env < len(os.Args); env++ {
 		key, value, ok := strings.Cut(os.Args[env], "=")
 		if !ok {
 			break
 		}
+		arch, key, ok := strings.Cut(key, ":")
+		if !ok {
+			arch, key = "", arch
+		} else if !slices.ContainsFunc(targets, func(t target) bool { return t.Arch == arch }) {
+			fmt.Fprintf(os.Stderr, "7zplugin: error: unknown arch %q for env var %s\n", arch, key)
+			os.Exit(2)
+		}
 		if strings.ContainsFunc(value, unicode.IsSpace) {
-			fmt.Printf("  %s=%q\n", key, value)
+			fmt.Printf("  %s=%q\n", os.Args[env][:len(os.Args[env])-len(value)-1], value)
 		} else {
-			fmt.Printf("  %s=%s\n", key, value)
+			fmt.Printf("  %s=%s\n", os.Args[env][:len(os.Args[env])-len(value)-1], value)
 		}
-		if err := os.Setenv(key, value); err != nil {
-			fmt.Fprintf(os.Stderr, "7zplugin: error: setenv(%q, %q): %v\n", key, value, err)
-			os.Exit(1)
+		if arch == "" {
+			envAll = append(envAll, key+"="+value)
+		} else {
+			envArch[arch] = append(envArch[arch], key+"="+value)
 		}
 	}
 	os.Args = slices.Delete(os.Args, 1, env)
 	fmt.Println()
 
-	// extract the output filename from args if present, or set the default
+	// extract the reproducible flag from args if present
+	var reproducible = verify
+	for i := 1; i < len(os.Args); i++ {
+		if arg := os.Args[i]; arg == "-reproducible" || arg == "--reproducible" {
+			reproducible = true
+			os.Args = slices.Delete(os.Args, i, i+1)
+			i--
+		}
+	}
+
+	// extract the output filename from args if present
 	var out string
 	for i, arg := range os.Args {
 		if i == 0 {
@@ -192,16 +331,13 @@ func main() {
 			os.Args = slices.Delete(os.Args, i, i+1)
 		} else if arg == "-o" {
 			if i+1 >= len(os.Args) {
-				fmt.Fprintf(os.Stderr, "error: error: expected output file after -o flag, got nothing\n")
+				fmt.Fprintf(os.Stderr, "7zplugin: error: expected output file after -o flag, got nothing\n")
 				os.Exit(1)
 			}
 			out = os.Args[i+1]
 			os.Args = slices.Delete(os.Args, i, i+2)
 		}
 	}
-	if out == "" {
-		out = dllname + arch + ".dll"
-	}
 
 	// extract package names from args
 	var (
@@ -227,7 +363,7 @@ func main() {
 		}
 		os.Args = slices.Delete(os.Args, i, i+1)
 	}
-	if pkg, err = goList(pkg...); err != nil {
+	if pkg, err = goList(buildEnv(build[0], envAll, envArch), pkg...); err != nil {
 		fmt.Fprintf(os.Stderr, "7zplugin: error: expand package list: %v\n", err)
 		os.Exit(1)
 	}
@@ -235,6 +371,19 @@ func main() {
 	// add the default build flags
 	os.Args = slices.Insert(os.Args, 1, "-buildmode=c-shared")
 
+	// make the build reproducible
+	if reproducible {
+		slices.Sort(pkg)
+		pkg = slices.Compact(pkg)
+		slices.Sort(files)
+		files = slices.Compact(files)
+		if !slices.Contains(os.Args, "-trimpath") && !slices.Contains(os.Args, "-trimpath=true") {
+			os.Args = slices.Insert(os.Args, 2, "-trimpath")
+		}
+		os.Args = addLdflags(os.Args, "-buildid=")
+		os.Args = addExtldflags(os.Args, "-Wl,--no-insert-timestamp")
+	}
+
 	// get the build date
 	built := time.Now().UTC()
 	if x, ok := os.LookupEnv("SOURCE_DATE_EPOCH"); ok {
@@ -244,9 +393,187 @@ func main() {
 			os.Exit(1)
 		}
 		built = time.Unix(n, 0)
+	} else if reproducible {
+		if built, err = gitCommitTime(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "7zplugin: error: SOURCE_DATE_EPOCH must be set for reproducible builds outside a git repository: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	// set the version info and default filename
+	// build into a temp dir to compare against the existing files
+	var verifyDir string
+	if verify {
+		if verifyDir, err = os.MkdirTemp("", "7zplugin-verify-*"); err != nil {
+			fmt.Fprintf(os.Stderr, "7zplugin: error: create temp dir: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// catch ctrl+c
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// prevent other builds from using the build dirs
+	unlock, err := lockBuild(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "7zplugin: error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// generate the manifest
+	fmt.Println("manifest")
+	manifest, err := buildManifest(ctx, dir, envAll, files, pkg)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			unlock()
+			fmt.Fprintf(os.Stderr, "interrupted\n")
+			os.Exit(1)
+		}
+		fmt.Printf("  warning: failed to generate manifest, skipping: %v\n", err)
+	} else {
+		sc := bufio.NewScanner(bytes.NewReader(manifest))
+		for sc.Scan() {
+			fmt.Printf("  %s\n", sc.Text())
+		}
+	}
+	fmt.Println()
+
+	// run the builds
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		res = make([]result, len(build))
+	)
+	for i, t := range build {
+		res[i].target = t
+
+		// set the output filename
+		res[i].out = dllname + t.Arch + ".dll"
+		if len(build) == 1 && out != "" {
+			res[i].out = out
+		} else if out != "" {
+			res[i].out = filepath.Join(out, res[i].out) // directory
+		}
+		if verify {
+			res[i].cmp, res[i].out = res[i].out, filepath.Join(verifyDir, filepath.Base(res[i].out))
+		}
+
+		// prefix the output if building multiple archs in parallel
+		var stdout, stderr io.Writer = os.Stdout, os.Stderr
+		if len(build) != 1 {
+			stdout = &prefixWriter{mu: &mu, w: os.Stdout, prefix: "[" + t.Arch + "] "}
+			stderr = &prefixWriter{mu: &mu, w: os.Stderr, prefix: "[" + t.Arch + "] "}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			res[i].err = buildArch(ctx, stdout, stderr, dir, t, buildEnv(t, envAll, envArch), os.Args[1:], files, pkg, res[i].out, versionInfo(t, built, pkg, files, reproducible))
+			if res[i].err == nil && manifest != nil {
+				if err := os.WriteFile(strings.TrimSuffix(res[i].out, filepath.Ext(res[i].out))+".json", manifest, 0666); err != nil {
+					res[i].err = fmt.Errorf("write manifest: %w", err)
+				}
+			}
+			if res[i].err == nil {
+				res[i].sum, res[i].err = sha256File(res[i].out)
+			}
+			if res[i].err == nil && res[i].cmp != "" {
+				if sum, err := sha256File(res[i].cmp); err != nil {
+					res[i].err = err
+				} else if sum != res[i].sum {
+					res[i].err = fmt.Errorf("%s (sha256 %s) does not match the rebuilt dll (sha256 %s)", res[i].cmp, sum, res[i].sum)
+				}
+			}
+			res[i].dur = time.Since(start)
+			for _, w := range []io.Writer{stdout, stderr} {
+				if w, ok := w.(*prefixWriter); ok {
+					w.Flush()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	unlock()
+
+	// remove the rebuilt files
+	if verifyDir != "" {
+		if err := os.RemoveAll(verifyDir); err != nil {
+			fmt.Printf("warning: failed to remove temp dir %s: %v\n", verifyDir, err)
+		}
+	}
+
+	// show the summary
+	var failed int
+	fmt.Println()
+	fmt.Println("summary")
+	for _, r := range res {
+		name := r.out
+		if r.cmp != "" {
+			name = r.cmp
+		}
+		if r.err != nil {
+			failed++
+			fmt.Printf("  %-5s  failed  %s (%s)\n", r.Arch, name, r.dur.Round(time.Millisecond))
+		} else if r.cmp != "" {
+			fmt.Printf("  %-5s  match   %s (%s) sha256 %s\n", r.Arch, name, r.dur.Round(time.Millisecond), r.sum)
+		} else {
+			fmt.Printf("  %-5s  ok      %s (%s) sha256 %s\n", r.Arch, name, r.dur.Round(time.Millisecond), r.sum)
+		}
+	}
+	fmt.Println()
+	if failed == 0 {
+		return
+	}
+
+	// show the errors
+	for _, r := range res {
+		if err := r.err; err != nil {
+			var prefix string
+			if len(res) != 1 {
+				prefix = "[" + r.Arch + "] "
+			}
+
+			// got ctrl+c
+			if errors.Is(err, context.Canceled) {
+				fmt.Fprintf(os.Stderr, "%sinterrupted\n", prefix)
+				continue
+			}
+
+			// go build exit status non-zero
+			var ee *exec.ExitError
+			if errors.As(err, &ee) {
+				status := ee.ExitCode()
+				fmt.Fprintf(os.Stderr, "%sgo build exited with status %d\n", prefix, status)
+				if len(res) == 1 {
+					os.Exit(status)
+				}
+				continue
+			}
+
+			// other error
+			fmt.Fprintf(os.Stderr, "%s7zplugin: error: %v\n", prefix, err)
+		}
+	}
+	os.Exit(1)
+}
+
+// buildEnv gets the environment for building t. If CC isn't set, the mingw-w64
+// compiler for t is used if it is in the PATH.
+func buildEnv(t target, envAll []string, envArch map[string][]string) []string {
+	env := append(os.Environ(), "CGO_ENABLED=1", "GOOS=windows", "GOARCH="+t.GOARCH)
+	env = append(env, envAll...)
+	env = append(env, envArch[t.Arch]...)
+	if !slices.ContainsFunc(env, func(x string) bool { return strings.HasPrefix(x, "CC=") }) {
+		if _, err := exec.LookPath(t.Mingw + "-gcc"); err == nil {
+			env = append(env, "CC="+t.Mingw+"-gcc", "CXX="+t.Mingw+"-g++")
+		}
+	}
+	return env
+}
+
+// versionInfo gets the version info for a build of t.
+func versionInfo(t target, built time.Time, pkg []string, files []string, reproducible bool) goversioninfo.VersionInfo {
 	var pluginDesc []string
 	for _, x := range pkg {
 		pluginDesc = append(pluginDesc, strings.TrimPrefix(x, "github.com/pg9182/7zplugin/plugins/"))
@@ -265,13 +592,13 @@ func main() {
 		},
 		StringFileInfo: goversioninfo.StringFileInfo{
 			ProductName:      "Go Plugins for 7-Zip (github.com/pg9182/7zplugin)",
-			ProductVersion:   "7-Zip " + z7.MY_VERSION_NUMBERS + "+ (" + z7arch + ")",
+			ProductVersion:   "7-Zip " + z7.MY_VERSION_NUMBERS + "+ (" + t.Z7Arch + ")",
 			FileDescription:  strings.Join(pluginDesc, ", "),
 			InternalName:     strings.ToUpper(dllname),
-			OriginalFilename: strings.ToUpper(dllname + arch + ".dll"),
+			OriginalFilename: strings.ToUpper(dllname + t.Arch + ".dll"),
 		},
 	}
-	if _, ok := os.LookupEnv("GITHUB_ACTION"); ok {
+	if _, ok := os.LookupEnv("GITHUB_ACTION"); ok && !reproducible {
 		if v, ok := os.LookupEnv("GITHUB_REPOSITORY_OWNER"); ok {
 			ver.StringFileInfo.CompanyName = v + " (github.com/" + v + ")"
 		}
@@ -288,50 +615,30 @@ func main() {
 		}
 	}
 	ver.StringFileInfo.FileVersion = ver.FixedFileInfo.FileVersion.GetVersionString() + ver.StringFileInfo.FileVersion
-
-	// catch ctrl+c
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer stop()
-
-	// run the build
-	if err = build(ctx, dir, os.Args[1:], files, pkg, out, ver); err != nil {
-
-		// got ctrl+c
-		if errors.Is(err, context.Canceled) {
-			fmt.Fprintf(os.Stderr, "interrupted\n")
-		}
-
-		// go build exit status non-zero
-		var ee *exec.ExitError
-		if errors.As(err, &ee) {
-			status := ee.ExitCode()
-			fmt.Fprintf(os.Stderr, "go build exited with status %d\n", status)
-			os.Exit(status)
-		}
-
-		// other error
-		fmt.Fprintf(os.Stderr, "7zplugin: error: %v\n", err)
-		os.Exit(1)
-	}
+	return ver
 }
 
-func build(ctx context.Context, dir string, flags []string, files []string, pkg []string, out string, ver goversioninfo.VersionInfo) error {
+func buildArch(ctx context.Context, stdout, stderr io.Writer, dir string, t target, env []string, flags []string, files []string, pkg []string, out string, ver goversioninfo.VersionInfo) error {
 	var err error
 
-	// make a temp dir
-	td := filepath.Join(dir, "z7plugin-build") // fixed name for reproducibility
+	// make a temp dir (the name is fixed since the package path is embedded in
+	// the dll, and stale ones are removed since we hold the build lock)
+	td := filepath.Join(dir, "z7plugin-build-"+t.GOARCH)
+	if err := os.RemoveAll(td); err != nil {
+		return fmt.Errorf("remove stale build dir: %w", err)
+	}
 	if err := os.Mkdir(td, 0777); err != nil {
 		return fmt.Errorf("create build dir: %w", err)
 	}
 	defer func() {
 		if err := os.RemoveAll(td); err != nil {
-			fmt.Printf("warning: failed to remove temp dir %s: %v\n", td, err)
+			fmt.Fprintf(stdout, "warning: failed to remove temp dir %s: %v\n", td, err)
 			return
 		}
 	}()
 
 	// show the temp dir
-	fmt.Printf("in %s\n\n", td)
+	fmt.Fprintf(stdout, "in %s\n\n", td)
 
 	// gitignore everything in it
 	if err := os.WriteFile(filepath.Join(td, ".gitignore"), []byte("*\n"), 0666); err != nil {
@@ -345,7 +652,7 @@ func build(ctx context.Context, dir string, flags []string, files []string, pkg
 		} else if err := os.WriteFile(filepath.Join(td, filepath.Base(x)), buf, 0666); err != nil {
 			return fmt.Errorf("copy standalone go file %s: write: %w", x, err)
 		}
-		fmt.Printf("%s\n  < %s\n\n", filepath.Base(x), filepath.Join(dir, x))
+		fmt.Fprintf(stdout, "%s\n  < %s\n\n", filepath.Base(x), filepath.Join(dir, x))
 	}
 
 	// generate the go source
@@ -365,33 +672,33 @@ func build(ctx context.Context, dir string, flags []string, files []string, pkg
 	}
 
 	// show the generated source file
-	fmt.Println("z7plugin.go")
+	fmt.Fprintln(stdout, "z7plugin.go")
 	sc, line := bufio.NewScanner(bytes.NewReader(src.Bytes())), 0
 	for sc.Scan() {
 		line++
-		fmt.Printf("%3d | %s\n", line, sc.Text())
+		fmt.Fprintf(stdout, "%3d | %s\n", line, sc.Text())
 	}
-	fmt.Println()
+	fmt.Fprintln(stdout)
 
 	// generate the resource files
 	ver.Build()
 	ver.Walk()
 
 	// save the resource file syso
-	if err := ver.WriteSyso(filepath.Join(td, "rsrc.syso"), os.Getenv("GOARCH")); err != nil {
+	if err := ver.WriteSyso(filepath.Join(td, "rsrc.syso"), t.GOARCH); err != nil {
 		return fmt.Errorf("generate rsrc syso: %w", err)
 	}
 
 	// show the generated resource file
-	fmt.Println("rsrc.syso")
+	fmt.Fprintln(stdout, "rsrc.syso")
 	for v, i := reflect.ValueOf(ver.StringFileInfo), 0; i < v.NumField(); i++ {
 		if f := v.Type().Field(i); f.Type.Kind() == reflect.String {
 			if x := v.Field(i).String(); x != "" {
-				fmt.Printf("%3d | %-16s   %q\n", i+1, f.Name, x)
+				fmt.Fprintf(stdout, "%3d | %-16s   %q\n", i+1, f.Name, x)
 			}
 		}
 	}
-	fmt.Println()
+	fmt.Fprintln(stdout)
 
 	// resolve the output path
 	if out, err = filepath.Abs(out); err != nil {
@@ -403,9 +710,9 @@ func build(ctx context.Context, dir string, flags []string, files []string, pkg
 	cmd.Args = append(cmd.Args, "-o", out)
 	cmd.Args = append(cmd.Args, flags...)
 	cmd.Dir = td
-	cmd.Env = os.Environ()
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	cmd.Stdin = nil
 
 	// show the build command
@@ -414,18 +721,302 @@ func build(ctx context.Context, dir string, flags []string, files []string, pkg
 			arg = strconv.Quote(arg)
 		}
 		if i != 0 {
-			fmt.Print(" ")
+			fmt.Fprint(stdout, " ")
 		}
-		fmt.Print(arg)
+		fmt.Fprint(stdout, arg)
 	}
-	fmt.Println()
+	fmt.Fprintln(stdout)
 
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("go build: %w", err)
 	}
+	fmt.Fprintln(stdout)
+
+	// verify the dll
+	fmt.Fprintln(stdout, "verify")
+	exports, err := verifyDLL(out, t)
+	for _, x := range exports {
+		fmt.Fprintf(stdout, "  export %s\n", x)
+	}
+	if err != nil {
+		return fmt.Errorf("verify %s: %w", out, err)
+	}
+	fmt.Fprintf(stdout, "  ok (%s)\n", peMachineName(t.Machine))
 	return nil
 }
 
+// addLdflags appends x to the last -ldflags flag, or adds one.
+func addLdflags(flags []string, x string) []string {
+	for i := len(flags) - 1; i >= 0; i-- {
+		switch flag, value, ok := strings.Cut(flags[i], "="); flag {
+		case "-ldflags", "--ldflags":
+			if ok {
+				flags[i] = flag + "=" + strings.TrimSpace(value+" "+x)
+				return flags
+			}
+			if i+1 < len(flags) {
+				flags[i+1] = strings.TrimSpace(flags[i+1] + " " + x)
+				return flags
+			}
+		}
+	}
+	return slices.Insert(flags, 1, "-ldflags="+x)
+}
+
+// lockBuild creates a lock file in dir so only one build uses the build dirs at
+// a time. The lock file is left behind if the build is killed, in which case
+// it must be removed manually.
+func lockBuild(dir string) (unlock func(), err error) {
+	name := filepath.Join(dir, "z7plugin-build.lock")
+	if err := writeFileExcl(name, []byte(strconv.Itoa(os.Getpid())+"\n"), 0666); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("another build is running in %s (remove %s if it isn't)", dir, name)
+		}
+		return nil, fmt.Errorf("create lock file: %w", err)
+	}
+	return func() {
+		if err := os.Remove(name); err != nil {
+			fmt.Printf("warning: failed to remove lock file %s: %v\n", name, err)
+		}
+	}, nil
+}
+
+// addExtldflags appends x to the last -extldflags flag in the last -ldflags
+// flag, or adds one. It needs to be merged since the linker only uses the last
+// -extldflags.
+func addExtldflags(flags []string, x string) []string {
+	var ldflags string
+	for i := len(flags) - 1; i >= 0 && ldflags == ""; i-- {
+		switch flag, value, ok := strings.Cut(flags[i], "="); flag {
+		case "-ldflags", "--ldflags":
+			if ok {
+				ldflags = value
+			} else if i+1 < len(flags) {
+				ldflags = flags[i+1]
+			}
+		}
+	}
+	fields, err := splitQuoted(ldflags)
+	if err != nil {
+		return addLdflags(flags, "-extldflags="+x) // let go build report it
+	}
+	for i := len(fields) - 1; i >= 0; i-- {
+		switch flag, value, ok := strings.Cut(fields[i], "="); flag {
+		case "-extldflags", "--extldflags":
+			if ok {
+				fields[i] = flag
+				fields = slices.Insert(fields, i+1, value)
+			} else if i+1 == len(fields) {
+				continue
+			}
+			fields[i+1] = strings.TrimSpace(fields[i+1] + " " + x)
+			return setLdflags(flags, joinQuoted(fields))
+		}
+	}
+	return addLdflags(flags, "-extldflags="+x)
+}
+
+// setLdflags replaces the value of the last -ldflags flag.
+func setLdflags(flags []string, x string) []string {
+	for i := len(flags) - 1; i >= 0; i-- {
+		switch flag, _, ok := strings.Cut(flags[i], "="); flag {
+		case "-ldflags", "--ldflags":
+			if ok {
+				flags[i] = flag + "=" + x
+				return flags
+			}
+			if i+1 < len(flags) {
+				flags[i+1] = x
+				return flags
+			}
+		}
+	}
+	return slices.Insert(flags, 1, "-ldflags="+x)
+}
+
+// splitQuoted splits a flag value like go build does (fields are separated by
+// whitespace, and may be entirely quoted with ' or ").
+func splitQuoted(s string) ([]string, error) {
+	var fields []string
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			return fields, nil
+		}
+		if q := s[0]; q == '\'' || q == '"' {
+			i := strings.IndexByte(s[1:], q)
+			if i == -1 {
+				return nil, fmt.Errorf("unterminated %c string", q)
+			}
+			fields, s = append(fields, s[1:1+i]), s[2+i:]
+			continue
+		}
+		i := strings.IndexFunc(s, unicode.IsSpace)
+		if i == -1 {
+			i = len(s)
+		}
+		fields, s = append(fields, s[:i]), s[i:]
+	}
+}
+
+// joinQuoted is the inverse of splitQuoted.
+func joinQuoted(fields []string) string {
+	var b strings.Builder
+	for i, x := range fields {
+		if i != 0 {
+			b.WriteByte(' ')
+		}
+		switch {
+		case x != "" && !strings.ContainsFunc(x, unicode.IsSpace) && x[0] != '\'' && x[0] != '"':
+			b.WriteString(x)
+		case !strings.ContainsRune(x, '\''):
+			b.WriteString("'" + x + "'")
+		default:
+			b.WriteString(`"` + x + `"`)
+		}
+	}
+	return b.String()
+}
+
+// gitCommitTime gets the commit time of the HEAD of the git repository
+// containing dir.
+func gitCommitTime(dir string) (time.Time, error) {
+	cmd := exec.Command("git", "log", "-1", "--format=%ct")
+	cmd.Dir = dir
+	cmd.Stdin = nil
+	cmd.Stderr = nil
+	buf, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("run command %q: %w", cmd.Args, err)
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(buf)), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse output of command %q: %w", cmd.Args, err)
+	}
+	return time.Unix(n, 0).UTC(), nil
+}
+
+// sha256File gets the hex sha256 of a file.
+func sha256File(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// buildManifest evaluates the registrations on the host, returning the
+// z7plugin.Manifest as JSON.
+func buildManifest(ctx context.Context, dir string, envAll []string, files []string, pkg []string) ([]byte, error) {
+	// make a temp dir (see buildArch)
+	td := filepath.Join(dir, "z7plugin-manifest")
+	if err := os.RemoveAll(td); err != nil {
+		return nil, fmt.Errorf("remove stale build dir: %w", err)
+	}
+	if err := os.Mkdir(td, 0777); err != nil {
+		return nil, fmt.Errorf("create build dir: %w", err)
+	}
+	defer os.RemoveAll(td)
+
+	// copy the standalone go files
+	for _, x := range files {
+		if buf, err := os.ReadFile(x); err != nil {
+			return nil, fmt.Errorf("copy standalone go file %s: read: %w", x, err)
+		} else if err := os.WriteFile(filepath.Join(td, filepath.Base(x)), buf, 0666); err != nil {
+			return nil, fmt.Errorf("copy standalone go file %s: write: %w", x, err)
+		}
+	}
+
+	// generate the go source
+	var src bytes.Buffer
+	fmt.Fprintln(&src, "package main")
+	fmt.Fprintln(&src)
+	fmt.Fprintf(&src, "import %q\n", "encoding/json")
+	fmt.Fprintf(&src, "import %q\n", "os")
+	fmt.Fprintf(&src, "import %q\n", "github.com/pg9182/7zplugin/z7plugin")
+	for _, x := range pkg {
+		fmt.Fprintf(&src, "import _ %q\n", x)
+	}
+	fmt.Fprintln(&src)
+	fmt.Fprintln(&src, "func main() {")
+	fmt.Fprintln(&src, "\te := json.NewEncoder(os.Stdout)")
+	fmt.Fprintln(&src, "\te.SetIndent(\"\", \"  \")")
+	fmt.Fprintln(&src, "\tif err := e.Encode(z7plugin.GetManifest()); err != nil {")
+	fmt.Fprintln(&src, "\t\tpanic(err)")
+	fmt.Fprintln(&src, "\t}")
+	fmt.Fprintln(&src, "}")
+
+	// save the go source
+	if err := writeFileExcl(filepath.Join(td, "z7plugin.go"), src.Bytes(), 0666); err != nil {
+		return nil, fmt.Errorf("save generated go source: %w", err)
+	}
+
+	// run it on the host
+	var buf, errbuf bytes.Buffer
+	cmd := exec.CommandContext(ctx, "go", "run", ".")
+	cmd.Dir = td
+	cmd.Env = append(os.Environ(), "GOOS="+runtime.GOOS, "GOARCH="+runtime.GOARCH)
+	for _, x := range envAll {
+		if !strings.HasPrefix(x, "CC=") && !strings.HasPrefix(x, "CXX=") {
+			cmd.Env = append(cmd.Env, x) // the compilers are for the dll
+		}
+	}
+	cmd.Stdout = &buf
+	cmd.Stderr = &errbuf
+	cmd.Stdin = nil
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("go run: %w\n%s", err, bytes.TrimSpace(errbuf.Bytes()))
+	}
+	return buf.Bytes(), nil
+}
+
+// prefixWriter prefixes each line written to w, writing only whole lines while
+// holding mu.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	if i := bytes.LastIndexByte(p.buf, '\n'); i != -1 {
+		p.write(p.buf[:i+1])
+		p.buf = p.buf[:copy(p.buf, p.buf[i+1:])]
+	}
+	return len(b), nil
+}
+
+// Flush writes the last line if it wasn't terminated.
+func (p *prefixWriter) Flush() {
+	if len(p.buf) != 0 {
+		p.write(append(p.buf, '\n'))
+		p.buf = p.buf[:0]
+	}
+}
+
+func (p *prefixWriter) write(lines []byte) {
+	var b []byte
+	for len(lines) != 0 {
+		i := bytes.IndexByte(lines, '\n') + 1
+		b = append(append(b, p.prefix...), lines[:i]...)
+		lines = lines[i:]
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.w.Write(b)
+}
+
 func goEnv() ([]string, error) {
 	var buf bytes.Buffer
 
@@ -453,7 +1044,7 @@ func goEnv() ([]string, error) {
 	return env, nil
 }
 
-func goList(pkg ...string) ([]string, error) {
+func goList(env []string, pkg ...string) ([]string, error) {
 	var buf bytes.Buffer
 
 	cmd := exec.Command("go", "list", "-json", "--")
@@ -461,7 +1052,7 @@ func goList(pkg ...string) ([]string, error) {
 	cmd.Stdin = nil
 	cmd.Stdout = &buf
 	cmd.Stderr = os.Stderr
-	cmd.Env = os.Environ()
+	cmd.Env = env
 
 	if err := cmd.Run(); err != nil {
 		return nil, fmt.Errorf("run command %q: %w", cmd.Args, err)
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAddLdflags(t *testing.T) {
+	for _, tc := range []struct {
+		flags []string
+		x     string
+		want  []string
+	}{
+		{
+			flags: []string{"7zplugin", "-trimpath", "./plugins/..."},
+			x:     "-buildid=",
+			want:  []string{"7zplugin", "-ldflags=-buildid=", "-trimpath", "./plugins/..."},
+		},
+		{
+			flags: []string{"7zplugin", "-ldflags", "-s -w", "./plugins/..."},
+			x:     "-buildid=",
+			want:  []string{"7zplugin", "-ldflags", "-s -w -buildid=", "./plugins/..."},
+		},
+		{
+			flags: []string{"7zplugin", "-ldflags=-s -w", "./plugins/..."},
+			x:     "-buildid=",
+			want:  []string{"7zplugin", "-ldflags=-s -w -buildid=", "./plugins/..."},
+		},
+		{
+			flags: []string{"7zplugin", "-ldflags=", "./plugins/..."},
+			x:     "-buildid=",
+			want:  []string{"7zplugin", "-ldflags=-buildid=", "./plugins/..."},
+		},
+		{
+			flags: []string{"7zplugin", "--ldflags=-s", "-ldflags", "-w", "./plugins/..."},
+			x:     "-buildid=",
+			want:  []string{"7zplugin", "--ldflags=-s", "-ldflags", "-w -buildid=", "./plugins/..."},
+		},
+	} {
+		if got := addLdflags(slices.Clone(tc.flags), tc.x); !slices.Equal(got, tc.want) {
+			t.Errorf("addLdflags(%q, %q) = %q; expected %q", tc.flags, tc.x, got, tc.want)
+		}
+	}
+}
+
+func TestAddExtldflags(t *testing.T) {
+	const x = "-Wl,--no-insert-timestamp"
+	for _, tc := range []struct {
+		flags []string
+		want  []string
+	}{
+		{
+			flags: []string{"7zplugin", "./plugins/..."},
+			want:  []string{"7zplugin", "-ldflags=-extldflags=" + x, "./plugins/..."},
+		},
+		{
+			flags: []string{"7zplugin", "-ldflags", "-s -w", "./plugins/..."},
+			want:  []string{"7zplugin", "-ldflags", "-s -w -extldflags=" + x, "./plugins/..."},
+		},
+		{
+			flags: []string{"7zplugin", "-ldflags=-s -w -extldflags=-static", "./plugins/..."},
+			want:  []string{"7zplugin", "-ldflags=-s -w -extldflags '-static " + x + "'", "./plugins/..."},
+		},
+		{
+			flags: []string{"7zplugin", "-ldflags", "-extldflags '-static -s' -w", "./plugins/..."},
+			want:  []string{"7zplugin", "-ldflags", "-extldflags '-static -s " + x + "' -w", "./plugins/..."},
+		},
+		{
+			flags: []string{"7zplugin", "-ldflags=-extldflags \"-static\"", "./plugins/..."},
+			want:  []string{"7zplugin", "-ldflags=-extldflags '-static " + x + "'", "./plugins/..."},
+		},
+	} {
+		if got := addExtldflags(slices.Clone(tc.flags), x); !slices.Equal(got, tc.want) {
+			t.Errorf("addExtldflags(%q) = %q; expected %q", tc.flags, got, tc.want)
+		}
+	}
+}
+
+func TestSplitQuoted(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"  -s\t-w ", []string{"-s", "-w"}},
+		{`-extldflags '-static -s' "a 'b'" ''`, []string{"-extldflags", "-static -s", "a 'b'", ""}},
+	} {
+		got, err := splitQuoted(tc.s)
+		if err != nil || !slices.Equal(got, tc.want) {
+			t.Errorf("splitQuoted(%q) = %q, %v; expected %q", tc.s, got, err, tc.want)
+		}
+		if got, err := splitQuoted(joinQuoted(tc.want)); err != nil || !slices.Equal(got, tc.want) {
+			t.Errorf("splitQuoted(joinQuoted(%q)) = %q, %v", tc.want, got, err)
+		}
+	}
+	if _, err := splitQuoted("-s '-w"); err == nil {
+		t.Errorf("expected error for unterminated string")
+	}
+}
@@ -0,0 +1,268 @@
+// Command z7run uses the 7-Zip plugin handlers natively (e.g., on Linux)
+// without 7-Zip, for scripting and debugging.
+//
+// It uses the same format registrations as the plugin DLL. To use other
+// plugins, add them to the imports.
+//
+// Usage:
+//
+//	z7run i                   show the registered formats
+//	z7run l archive           list the contents of an archive
+//	z7run t archive           test the integrity of an archive
+//	z7run x [-o dir] archive  extract an archive (to the current directory by default)
+//
+// Files next to the archive are available to handlers for multi-file formats.
+// If Z7PLUGIN_SETTINGS is set, the format settings are loaded from it (see
+// z7plugin.LoadSettings).
+//
+// The exit status is 0 on success, 2 if an error occurred, and 7 if the
+// arguments are invalid, like 7z.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/pg9182/7zplugin/plugins/tf2vpk"
+	"github.com/pg9182/7zplugin/z7plugin"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	if name := os.Getenv("Z7PLUGIN_SETTINGS"); name != "" {
+		f, err := os.Open(name)
+		if err == nil {
+			err = z7plugin.LoadSettings(f)
+			f.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "z7run: error: load settings: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "i":
+		if len(args) != 0 {
+			usage()
+		}
+		err = info()
+	case "l", "t":
+		if len(args) != 1 {
+			usage()
+		}
+		if cmd == "l" {
+			err = list(ctx, args[0])
+		} else {
+			err = test(ctx, args[0])
+		}
+	case "x":
+		fset := flag.NewFlagSet("x", flag.ExitOnError)
+		out := fset.String("o", ".", "output directory")
+		fset.Parse(args)
+		if fset.NArg() != 1 {
+			usage()
+		}
+		err = extract(ctx, fset.Arg(0), *out)
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "z7run: error: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s i|l|t|x [-o dir] [archive]\n", filepath.Base(os.Args[0]))
+	os.Exit(7)
+}
+
+func info() error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "Name\tExt\tUpdate\tOffset\tSignature\tFlags")
+	for _, f := range z7plugin.GetManifest().Formats {
+		var update string
+		if f.Update {
+			update = "U"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n", f.Name, strings.Join(f.Ext, " "), update, f.SignatureOffset, strings.Join(f.Signatures, " "), strings.Join(f.Flags, " "))
+	}
+	return tw.Flush()
+}
+
+// open opens an archive, showing the format.
+func open(ctx context.Context, name string) (*z7plugin.ArchiveFS, error) {
+	afs, arc, err := z7plugin.OpenArchiveFS(ctx, name)
+	if err != nil {
+		if arc != nil {
+			return nil, fmt.Errorf("open %s as %s: %w", name, arc.Name, err)
+		}
+		return nil, err
+	}
+	fmt.Printf("Path = %s\nType = %s\n\n", name, arc.Name)
+	return afs, nil
+}
+
+func list(ctx context.Context, name string) error {
+	afs, err := open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer afs.Close()
+
+	var (
+		files, dirs    int
+		size, packSize uint64
+	)
+	fmt.Printf("%-19s  %-10s  %12s  %12s  %s\n", "Date", "Mode", "Size", "Packed", "Name")
+	if err := fs.WalkDir(afs, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == "." {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		it := fi.Sys().(z7plugin.Item)
+		var date string
+		if t := fi.ModTime(); !t.IsZero() {
+			date = t.Format(time.DateTime)
+		}
+		if fi.IsDir() {
+			dirs++
+			fmt.Printf("%-19s  %-10s  %12s  %12s  %s\n", date, fi.Mode(), "", "", p)
+		} else {
+			files++
+			size += it.Size
+			packSize += it.PackSize
+			fmt.Printf("%-19s  %-10s  %12d  %12d  %s\n", date, fi.Mode(), it.Size, it.PackSize, p)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("%-19s  %-10s  %12d  %12d  %d files, %d folders\n", "", "", size, packSize, files, dirs)
+	return nil
+}
+
+func test(ctx context.Context, name string) error {
+	afs, err := open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer afs.Close()
+
+	var n, failed int
+	if err := fs.WalkDir(afs, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		n++
+		if err := afs.Extract(ctx, p, io.Discard); err != nil {
+			failed++
+			fmt.Printf("ERROR: %v\n", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("Files: %d\nErrors: %d\n", n, failed)
+	if failed != 0 {
+		return errors.New("archive has errors")
+	}
+	return nil
+}
+
+func extract(ctx context.Context, name, out string) error {
+	afs, err := open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer afs.Close()
+
+	var failed int
+	var dirs []fs.FileInfo
+	if err := fs.WalkDir(afs, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(out, filepath.FromSlash(p))
+		if d.IsDir() {
+			if p != "." {
+				dirs = append(dirs, fi)
+			}
+			return os.MkdirAll(dst, 0777)
+		}
+		fmt.Printf("- %s\n", p)
+		if err := extractFile(ctx, afs, p, dst, fi); err != nil {
+			failed++
+			fmt.Printf("ERROR: %v\n", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// set the directory times after their contents are extracted
+	for _, fi := range dirs {
+		if t := fi.ModTime(); !t.IsZero() {
+			os.Chtimes(filepath.Join(out, filepath.FromSlash(fi.Sys().(z7plugin.Item).Path)), t, t)
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("failed to extract %d files", failed)
+	}
+	return nil
+}
+
+func extractFile(ctx context.Context, afs *z7plugin.ArchiveFS, p, dst string, fi fs.FileInfo) error {
+	perm := fi.Mode().Perm() | 0200
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if err := afs.Extract(ctx, p, w); err != nil {
+		w.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := w.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	if t := fi.ModTime(); !t.IsZero() {
+		return os.Chtimes(dst, t, t)
+	}
+	return nil
+}
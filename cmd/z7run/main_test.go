@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeVPK writes a VPK directory file containing readme.txt with data stored
+// after the tree, but with the CRC of crcData.
+func writeVPK(t *testing.T, name, data, crcData string) {
+	t.Helper()
+
+	var tree []byte
+	tree = append(tree, "txt\x00 \x00readme\x00"...)
+	tree = binary.LittleEndian.AppendUint32(tree, crc32.ChecksumIEEE([]byte(crcData)))
+	tree = binary.LittleEndian.AppendUint16(tree, 0)      // preload bytes
+	tree = binary.LittleEndian.AppendUint16(tree, 0x7FFF) // archive index (dir file)
+	tree = binary.LittleEndian.AppendUint32(tree, 0x101)  // load flags
+	tree = binary.LittleEndian.AppendUint16(tree, 0)      // texture flags
+	tree = binary.LittleEndian.AppendUint64(tree, 0)      // offset
+	tree = binary.LittleEndian.AppendUint64(tree, uint64(len(data)))
+	tree = binary.LittleEndian.AppendUint64(tree, uint64(len(data)))
+	tree = binary.LittleEndian.AppendUint16(tree, 0xFFFF)
+	tree = append(tree, 0, 0, 0)
+
+	var b []byte
+	b = binary.LittleEndian.AppendUint32(b, 0x55AA1234)
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	b = binary.LittleEndian.AppendUint16(b, 3)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(tree)))
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = append(b, tree...)
+	b = append(b, data...)
+	if err := os.WriteFile(name, b, 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIntegrity(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		data    string
+		corrupt bool
+	}{
+		{"ok", "hello, world", false},
+		{"corrupt", "HELLO, world", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				dir  = t.TempDir()
+				name = filepath.Join(dir, "test_dir.vpk")
+				out  = filepath.Join(dir, "out")
+				dst  = filepath.Join(out, "readme.txt")
+			)
+			writeVPK(t, name, tc.data, "hello, world")
+
+			if err := test(context.Background(), name); (err != nil) != tc.corrupt {
+				t.Errorf("test: unexpected result %v", err)
+			}
+
+			err := extract(context.Background(), name, out)
+			if (err != nil) != tc.corrupt {
+				t.Errorf("extract: unexpected result %v", err)
+			}
+			if buf, err := os.ReadFile(dst); tc.corrupt {
+				if !os.IsNotExist(err) {
+					t.Errorf("extract: expected corrupt file to be removed, got %q, %v", buf, err)
+				}
+			} else if err != nil || string(buf) != tc.data {
+				t.Errorf("extract: got %q, %v", buf, err)
+			}
+		})
+	}
+}
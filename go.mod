@@ -1,6 +1,6 @@
 module github.com/pg9182/7zplugin
 
-go 1.22.0
+go 1.23.0
 
 require (
 	github.com/josephspurrier/goversioninfo v1.4.0
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"debug/pe"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// z7Target detects the arch of the 7-Zip installation in dir from the PE
+// machine type of 7z.dll and 7z.exe.
+func z7Target(dir string) (target, error) {
+	var machine uint16
+	for _, name := range []string{"7z.dll", "7z.exe"} {
+		f, err := pe.Open(filepath.Join(dir, name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return target{}, fmt.Errorf("read %s: %w", name, err)
+		}
+		m := f.Machine
+		f.Close()
+
+		if machine != 0 && machine != m {
+			return target{}, fmt.Errorf("7z.dll and 7z.exe have different machine types (%s, %s)", peMachineName(machine), peMachineName(m))
+		}
+		machine = m
+	}
+	if machine == 0 {
+		return target{}, fmt.Errorf("7z.dll or 7z.exe not found in %s", dir)
+	}
+	for _, t := range targets {
+		if t.Machine == machine {
+			return t, nil
+		}
+	}
+	return target{}, fmt.Errorf("unsupported 7-Zip machine type %s", peMachineName(machine))
+}
+
+// installed gets the names of the plugin DLLs in the Formats directory of the
+// 7-Zip installation in dir.
+func installed(dir string) ([]string, error) {
+	es, err := os.ReadDir(filepath.Join(dir, "Formats"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, e := range es {
+		if name := strings.ToLower(e.Name()); !e.IsDir() && strings.HasPrefix(name, strings.ToLower(dllname)) && strings.HasSuffix(name, ".dll") {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// install installs the plugin into the 7-Zip installation in args[0]. The
+// remaining args are either the DLLs to choose from, or the arguments to build
+// it with. If there are no remaining args, the default DLL for the arch in the
+// current directory is used.
+func install(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected 7-Zip directory")
+	}
+	dir, args := args[0], args[1:]
+
+	// detect the 7-Zip arch
+	t, err := z7Target(dir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("7-Zip in %s is %s\n", dir, peMachineName(t.Machine))
+
+	// find or build the dll
+	var dll string
+	switch {
+	case len(args) == 0:
+		dll = dllname + t.Arch + ".dll"
+
+	case !strings.HasSuffix(strings.ToLower(args[0]), ".dll"):
+		td, err := os.MkdirTemp("", "7zplugin-install-*")
+		if err != nil {
+			return fmt.Errorf("create temp dir: %w", err)
+		}
+		defer os.RemoveAll(td)
+
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("get executable: %w", err)
+		}
+		dll = filepath.Join(td, dllname+t.Arch+".dll")
+
+		// the output flag must be after the env vars and before the packages
+		var env int
+		for env < len(args) && !strings.HasPrefix(args[env], "-") && strings.Contains(args[env], "=") {
+			env++
+		}
+		cmd := exec.Command(exe, t.Arch)
+		cmd.Args = append(cmd.Args, args[:env]...)
+		cmd.Args = append(cmd.Args, "-o="+dll)
+		cmd.Args = append(cmd.Args, args[env:]...)
+		cmd.Stdin = nil
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("build: %w", err)
+		}
+
+	default:
+		for _, x := range args {
+			f, err := pe.Open(x)
+			if err != nil {
+				return fmt.Errorf("read %s: %w", x, err)
+			}
+			m := f.Machine
+			f.Close()
+
+			fmt.Printf("%s is %s\n", x, peMachineName(m))
+			if m == t.Machine && dll == "" {
+				dll = x
+			}
+		}
+		if dll == "" {
+			return fmt.Errorf("none of the dlls match the 7-Zip arch")
+		}
+	}
+	if _, err := verifyDLL(dll, t); err != nil {
+		return fmt.Errorf("verify %s: %w", dll, err)
+	}
+
+	// back up the previous versions
+	formats, backup := filepath.Join(dir, "Formats"), filepath.Join(dir, "Formats.bak")
+	old, err := installed(dir)
+	if err != nil {
+		return fmt.Errorf("find installed plugins: %w", err)
+	}
+	if len(old) != 0 {
+		// note: 7-Zip attempts to load all files in Formats, so the backup
+		// must be outside it
+		if err := os.MkdirAll(backup, 0777); err != nil {
+			return fmt.Errorf("create backup dir: %w", err)
+		}
+	}
+	for _, x := range old {
+		// keep the first backup since it's the version from before we
+		// installed the plugin (the current one is from a previous install)
+		if _, err := os.Lstat(filepath.Join(backup, x)); err == nil {
+			fmt.Printf("remove %s\n  (already backed up)\n", filepath.Join(formats, x))
+			if err := os.Remove(filepath.Join(formats, x)); err != nil {
+				return fmt.Errorf("remove %s: %w", x, err)
+			}
+			continue
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("backup %s: %w", x, err)
+		}
+		fmt.Printf("backup %s\n  > %s\n", filepath.Join(formats, x), filepath.Join(backup, x))
+		if err := os.Rename(filepath.Join(formats, x), filepath.Join(backup, x)); err != nil {
+			return fmt.Errorf("backup %s: %w", x, err)
+		}
+	}
+
+	// copy the dll
+	dst := filepath.Join(formats, dllname+t.Arch+".dll")
+	fmt.Printf("install %s\n  > %s\n", dll, dst)
+	if err := os.MkdirAll(formats, 0777); err != nil {
+		return fmt.Errorf("create formats dir: %w", err)
+	}
+	if err := copyFile(dst, dll); err != nil {
+		return fmt.Errorf("install %s: %w", dll, err)
+	}
+	return nil
+}
+
+// uninstall removes the plugin from the 7-Zip installation in args[0], and
+// restores the backups of the previous versions.
+func uninstall(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected 7-Zip directory")
+	}
+	dir := args[0]
+	formats, backup := filepath.Join(dir, "Formats"), filepath.Join(dir, "Formats.bak")
+
+	old, err := installed(dir)
+	if err != nil {
+		return fmt.Errorf("find installed plugins: %w", err)
+	}
+	if len(old) == 0 {
+		return fmt.Errorf("no plugins installed in %s", formats)
+	}
+	for _, x := range old {
+		fmt.Printf("remove %s\n", filepath.Join(formats, x))
+		if err := os.Remove(filepath.Join(formats, x)); err != nil {
+			return fmt.Errorf("remove %s: %w", x, err)
+		}
+	}
+
+	// restore the backups
+	es, err := os.ReadDir(backup)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("read backup dir: %w", err)
+	}
+	var kept int
+	for _, e := range es {
+		if name := strings.ToLower(e.Name()); e.IsDir() || !strings.HasPrefix(name, strings.ToLower(dllname)) || !strings.HasSuffix(name, ".dll") {
+			kept++
+			continue
+		}
+		fmt.Printf("restore %s\n  > %s\n", filepath.Join(backup, e.Name()), filepath.Join(formats, e.Name()))
+		if err := os.Rename(filepath.Join(backup, e.Name()), filepath.Join(formats, e.Name())); err != nil {
+			return fmt.Errorf("restore %s: %w", e.Name(), err)
+		}
+	}
+	if kept != 0 {
+		return nil
+	}
+	if err := os.Remove(backup); err != nil {
+		fmt.Printf("warning: failed to remove backup dir %s: %v\n", backup, err)
+	}
+	return nil
+}
+
+func copyFile(dst, src string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := w.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
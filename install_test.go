@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePE writes a minimal PE file for machine exporting the specified
+// functions.
+func writePE(t *testing.T, name string, machine uint16, dll bool, exports ...string) {
+	t.Helper()
+
+	const (
+		fileAlign = 0x200
+		rva       = 0x1000
+	)
+
+	// export directory, name rvas, names
+	var data []byte
+	data = binary.LittleEndian.AppendUint32(make([]byte, 24), uint32(len(exports)))
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	data = binary.LittleEndian.AppendUint32(data, rva+40)
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	off := 40 + 4*len(exports)
+	for _, x := range exports {
+		data = binary.LittleEndian.AppendUint32(data, uint32(rva+off))
+		off += len(x) + 1
+	}
+	for _, x := range exports {
+		data = append(append(data, x...), 0)
+	}
+	size := (uint32(len(data)) + fileAlign - 1) &^ (fileAlign - 1)
+	data = append(data, make([]byte, int(size)-len(data))...)
+
+	dd := [16]pe.DataDirectory{
+		pe.IMAGE_DIRECTORY_ENTRY_EXPORT: {VirtualAddress: rva, Size: uint32(len(data))},
+	}
+	var oh any
+	if machine == pe.IMAGE_FILE_MACHINE_I386 {
+		oh = &pe.OptionalHeader32{Magic: 0x10b, SectionAlignment: rva, FileAlignment: fileAlign, SizeOfImage: rva + size, SizeOfHeaders: fileAlign, NumberOfRvaAndSizes: 16, DataDirectory: dd}
+	} else {
+		oh = &pe.OptionalHeader64{Magic: 0x20b, SectionAlignment: rva, FileAlignment: fileAlign, SizeOfImage: rva + size, SizeOfHeaders: fileAlign, NumberOfRvaAndSizes: 16, DataDirectory: dd}
+	}
+	fh := pe.FileHeader{
+		Machine:              machine,
+		NumberOfSections:     1,
+		SizeOfOptionalHeader: uint16(binary.Size(oh)),
+		Characteristics:      pe.IMAGE_FILE_EXECUTABLE_IMAGE,
+	}
+	if dll {
+		fh.Characteristics |= pe.IMAGE_FILE_DLL
+	}
+	sh := pe.SectionHeader32{
+		Name:             [8]uint8{'.', 'e', 'd', 'a', 't', 'a'},
+		VirtualSize:      size,
+		VirtualAddress:   rva,
+		SizeOfRawData:    size,
+		PointerToRawData: fileAlign,
+		Characteristics:  pe.IMAGE_SCN_CNT_INITIALIZED_DATA | pe.IMAGE_SCN_MEM_READ,
+	}
+
+	var b bytes.Buffer
+	b.WriteString("MZ")
+	b.Write(make([]byte, 0x3C-b.Len()))
+	binary.Write(&b, binary.LittleEndian, uint32(0x40))
+	b.WriteString("PE\x00\x00")
+	binary.Write(&b, binary.LittleEndian, fh)
+	binary.Write(&b, binary.LittleEndian, oh)
+	binary.Write(&b, binary.LittleEndian, sh)
+	b.Write(make([]byte, fileAlign-b.Len()))
+	b.Write(data)
+	if err := os.WriteFile(name, b.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZ7Target(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		files   map[string]uint16
+		machine uint16
+		err     string
+	}{
+		{"exe", map[string]uint16{"7z.exe": pe.IMAGE_FILE_MACHINE_AMD64}, pe.IMAGE_FILE_MACHINE_AMD64, ""},
+		{"dll", map[string]uint16{"7z.dll": pe.IMAGE_FILE_MACHINE_I386}, pe.IMAGE_FILE_MACHINE_I386, ""},
+		{"both", map[string]uint16{"7z.exe": pe.IMAGE_FILE_MACHINE_ARM64, "7z.dll": pe.IMAGE_FILE_MACHINE_ARM64}, pe.IMAGE_FILE_MACHINE_ARM64, ""},
+		{"mismatch", map[string]uint16{"7z.exe": pe.IMAGE_FILE_MACHINE_AMD64, "7z.dll": pe.IMAGE_FILE_MACHINE_I386}, 0, "different machine types"},
+		{"unsupported", map[string]uint16{"7z.exe": pe.IMAGE_FILE_MACHINE_ARMNT}, 0, "unsupported"},
+		{"missing", nil, 0, "not found"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, machine := range tc.files {
+				writePE(t, filepath.Join(dir, name), machine, name == "7z.dll")
+			}
+			x, err := z7Target(dir)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if x.Machine != tc.machine {
+				t.Errorf("got machine %s, expected %s", peMachineName(x.Machine), peMachineName(tc.machine))
+			}
+		})
+	}
+}
+
+func TestInstall(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		formats = filepath.Join(dir, "Formats")
+		backup  = filepath.Join(dir, "Formats.bak")
+		name    = dllname + "64.dll"
+		dll64   = filepath.Join(t.TempDir(), "a64.dll")
+		dll32   = filepath.Join(t.TempDir(), "a32.dll")
+		bad64   = filepath.Join(t.TempDir(), "b64.dll")
+	)
+	writePE(t, filepath.Join(dir, "7z.exe"), pe.IMAGE_FILE_MACHINE_AMD64, false)
+	writePE(t, dll64, pe.IMAGE_FILE_MACHINE_AMD64, true, archive2Exports...)
+	writePE(t, dll32, pe.IMAGE_FILE_MACHINE_I386, true, archive2Exports...)
+	writePE(t, bad64, pe.IMAGE_FILE_MACHINE_AMD64, true, archive2Exports[1:]...)
+	if err := os.Mkdir(formats, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(formats, name), []byte("original"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(formats, "other.dll"), []byte("other"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(path, want string) {
+		t.Helper()
+		buf, err := os.ReadFile(path)
+		if want == "" {
+			if !os.IsNotExist(err) {
+				t.Errorf("%s: expected not to exist, got %v", path, err)
+			}
+			return
+		}
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		} else if string(buf) != want {
+			t.Errorf("%s: incorrect contents", path)
+		}
+	}
+	contents := func(path string) string {
+		t.Helper()
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf)
+	}
+
+	// arch mismatch
+	if err := install([]string{dir, dll32}); err == nil || !strings.Contains(err.Error(), "none of the dlls match") {
+		t.Errorf("expected arch mismatch error, got %v", err)
+	}
+	if err := install([]string{dir, bad64}); err == nil || !strings.Contains(err.Error(), "missing exports") {
+		t.Errorf("expected missing exports error, got %v", err)
+	}
+	check(filepath.Join(formats, name), "original")
+	check(filepath.Join(backup, name), "")
+
+	// install, backing up the original
+	if err := install([]string{dir, dll32, dll64}); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	check(filepath.Join(formats, name), contents(dll64))
+	check(filepath.Join(backup, name), "original")
+	check(filepath.Join(formats, "other.dll"), "other")
+
+	// reinstall, keeping the first backup
+	if err := install([]string{dir, dll64}); err != nil {
+		t.Fatalf("reinstall: %v", err)
+	}
+	check(filepath.Join(formats, name), contents(dll64))
+	check(filepath.Join(backup, name), "original")
+
+	// uninstall, restoring the original
+	if err := uninstall([]string{dir}); err != nil {
+		t.Fatalf("uninstall: %v", err)
+	}
+	check(filepath.Join(formats, name), "original")
+	check(filepath.Join(formats, "other.dll"), "other")
+	if _, err := os.Stat(backup); !os.IsNotExist(err) {
+		t.Errorf("expected backup dir to be removed, got %v", err)
+	}
+}
+
+func TestUninstallNotInstalled(t *testing.T) {
+	if err := uninstall([]string{t.TempDir()}); err == nil {
+		t.Errorf("expected error")
+	}
+}
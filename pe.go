@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// archive2Exports are the functions from CPP/7zip/Archive/Archive2.def which
+// must be exported by the DLL.
+var archive2Exports = []string{
+	"CreateObject",
+	"GetHandlerProperty2",
+	"GetNumberOfFormats",
+	"GetIsArc",
+	"GetNumberOfMethods",
+	"GetMethodProperty",
+	"CreateDecoder",
+	"CreateEncoder",
+	"GetHashers",
+	"SetLargePageMode",
+	"SetCaseSensitive",
+	"GetModuleProp",
+}
+
+// peMachineName gets a descriptive name for a PE machine type.
+func peMachineName(machine uint16) string {
+	for _, t := range targets {
+		if t.Machine == machine {
+			return fmt.Sprintf("%s (0x%04X)", t.GOARCH, machine)
+		}
+	}
+	return fmt.Sprintf("0x%04X", machine)
+}
+
+// verifyDLL checks that name is a DLL for t exporting archive2Exports.
+func verifyDLL(name string, t target) ([]string, error) {
+	f, err := pe.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if f.Characteristics&pe.IMAGE_FILE_DLL == 0 {
+		return nil, fmt.Errorf("not a dll (is -buildmode=c-shared set?)")
+	}
+	if f.Machine != t.Machine {
+		return nil, fmt.Errorf("wrong machine type %s, expected %s", peMachineName(f.Machine), peMachineName(t.Machine))
+	}
+
+	exports, err := peExports(f)
+	if err != nil {
+		return nil, fmt.Errorf("read exports: %w", err)
+	}
+	var missing []string
+	for _, x := range archive2Exports {
+		if !slices.Contains(exports, x) {
+			missing = append(missing, x)
+		}
+	}
+	if len(missing) != 0 {
+		return exports, fmt.Errorf("missing exports %q (is z7plugin linked?)", missing)
+	}
+	return exports, nil
+}
+
+// peExports gets the names of the functions exported by f.
+func peExports(f *pe.File) ([]string, error) {
+	var dd pe.DataDirectory
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if oh.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_EXPORT {
+			dd = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT]
+		}
+	case *pe.OptionalHeader64:
+		if oh.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_EXPORT {
+			dd = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT]
+		}
+	default:
+		return nil, fmt.Errorf("missing optional header")
+	}
+	if dd.VirtualAddress == 0 {
+		return nil, nil
+	}
+
+	// read reads data at a RVA
+	read := func(rva uint32, b []byte) error {
+		for _, s := range f.Sections {
+			if rva >= s.VirtualAddress && rva < s.VirtualAddress+s.VirtualSize {
+				_, err := s.ReadAt(b, int64(rva-s.VirtualAddress))
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return err
+			}
+		}
+		return fmt.Errorf("rva 0x%X not in any section", rva)
+	}
+
+	// IMAGE_EXPORT_DIRECTORY
+	var ed [40]byte
+	if err := read(dd.VirtualAddress, ed[:]); err != nil {
+		return nil, err
+	}
+	var (
+		numberOfNames  = binary.LittleEndian.Uint32(ed[24:])
+		addressOfNames = binary.LittleEndian.Uint32(ed[32:])
+	)
+	if numberOfNames > 1<<16 {
+		return nil, fmt.Errorf("too many exports (%d)", numberOfNames)
+	}
+	names := make([]byte, numberOfNames*4)
+	if err := read(addressOfNames, names); err != nil {
+		return nil, err
+	}
+	exports := make([]string, numberOfNames)
+	for i := range exports {
+		var b [256]byte
+		if err := read(binary.LittleEndian.Uint32(names[i*4:]), b[:]); err != nil && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		name, _, _ := bytes.Cut(b[:], []byte{0})
+		exports[i] = string(name)
+	}
+	return exports, nil
+}
@@ -0,0 +1,32 @@
+package tf2vpk
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/pg9182/7zplugin/z7plugin"
+)
+
+// OpenFS opens a VPK directory file (e.g.,
+// englishclient_mp_box.bsp.pak000_dir.vpk) as a fs.FS for use outside of
+// 7-Zip. The chunk files are read from the same directory.
+func OpenFS(name string) (*z7plugin.ArchiveFS, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	vol := z7plugin.DirVolumes(name)
+	afs, err := z7plugin.NewArchiveFS(context.Background(), new(reader), f, fi.Size(), vol)
+	if err != nil {
+		vol.(io.Closer).Close()
+		f.Close()
+		return nil, err
+	}
+	return afs, nil
+}
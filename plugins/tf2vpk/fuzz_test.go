@@ -0,0 +1,11 @@
+package tf2vpk
+
+import (
+	"testing"
+
+	"github.com/pg9182/7zplugin/z7plugin/z7fuzz"
+)
+
+func FuzzVPK(f *testing.F) {
+	z7fuzz.Fuzz(f, "englishclient_fuzz.bsp.pak000_dir.vpk")
+}
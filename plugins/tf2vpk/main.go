@@ -1,22 +1,40 @@
 // Package tf2vpk is a 7-Zip archive format plugin for Respawn VPKs as used in
 // Titanfall 2.
+//
+// The directory file (e.g., englishclient_mp_box.bsp.pak000_dir.vpk) should be
+// opened, and the chunk files (e.g., client_mp_box.bsp.pak000_000.vpk) must be
+// in the same directory, or in one of the directories in the chunkPaths setting.
+// LZHAM-compressed chunks cannot be extracted yet, but are preserved when
+// updating an archive.
+//
+// The package can also be used as a regular Go library on any platform (see
+// OpenFS).
 package tf2vpk
 
 import (
-	"github.com/lxn/win"
-	"github.com/pg9182/7zplugin/winext"
 	"github.com/pg9182/7zplugin/z7"
 	"github.com/pg9182/7zplugin/z7plugin"
 )
 
+// settings are loaded by z7plugin.
+var settings struct {
+	ChunkPaths []string `json:"chunkPaths"` // additional directories to search for chunk files
+}
+
 func init() {
 	z7plugin.RegisterArc(&z7plugin.CArcInfo{
-		Name:            "VPK0203",
-		CLSID:           winext.MustGUID[win.CLSID]("{3a128a09-88fe-45db-8727-565dff106ebe}"),
-		Ext:             "vpk",
-		AddExt:          "",
-		Flags:           z7.NArchive_NArcInfoFlags_kPureStartOpen,
-		Signature:       "\x55\xaa\x12\x34\x02\x00\x03\x00",
-		CreateInArchive: func() {}, // TODO
+		Name:      "VPK0203",
+		CLSID:     z7.MustGUID("{3a128a09-88fe-45db-8727-565dff106ebe}"),
+		Ext:       "vpk",
+		AddExt:    "",
+		Flags:     z7.NArchive_NArcInfoFlags_kPureStartOpen,
+		Signature: vpkSignature,
+		CreateInArchive: func() z7plugin.InArchive {
+			return new(reader)
+		},
+		CreateOutArchive: func() z7plugin.OutArchive {
+			return writer{}
+		},
+		Settings: &settings,
 	})
 }
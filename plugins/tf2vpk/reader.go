@@ -0,0 +1,255 @@
+package tf2vpk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/pg9182/7zplugin/z7"
+	"github.com/pg9182/7zplugin/z7plugin"
+)
+
+// Item properties used to preserve the chunk flags when updating an archive.
+const (
+	kpidLoadFlags    = z7.PROPID_kpidUserDefined + iota // uint32
+	kpidTextureFlags                                    // uint16
+)
+
+// reader reads a VPK directory file and the chunk files next to it.
+type reader struct {
+	r      io.ReaderAt
+	data   int64 // the offset of the data in the directory file
+	vol    z7plugin.Volumes
+	chunks map[uint16]io.ReaderAt
+	files  []*os.File // chunk files opened from settings.ChunkPaths
+	es     []vpkEntry
+}
+
+func (v *reader) Open(ctx context.Context, r io.ReaderAt, size int64, p z7plugin.Progress) error {
+	return v.OpenVolumes(ctx, r, size, nil, p)
+}
+
+func (v *reader) OpenVolumes(ctx context.Context, r io.ReaderAt, size int64, vol z7plugin.Volumes, p z7plugin.Progress) error {
+	es, data, err := readVPKDir(r, size)
+	if err != nil {
+		if err == errVPKFormat {
+			return z7plugin.ErrNotArchive
+		}
+		return err
+	}
+	if err := p.SetCompleted(uint64(len(es)), uint64(data)); err != nil {
+		return err
+	}
+	v.r, v.data, v.vol, v.es = r, data, vol, es
+	v.chunks = map[uint16]io.ReaderAt{}
+	return nil
+}
+
+func (v *reader) Close() error {
+	for _, f := range v.files {
+		f.Close()
+	}
+	*v = reader{}
+	return nil
+}
+
+func (v *reader) NumItems() int {
+	return len(v.es)
+}
+
+func (v *reader) Item(index int) (z7plugin.Item, error) {
+	e := v.es[index]
+	method := "Copy"
+	for _, c := range e.Chunks {
+		if c.compressed() {
+			method = "LZHAM"
+			break
+		}
+	}
+	return z7plugin.Item{
+		Path:     e.Path,
+		Size:     e.size(),
+		PackSize: e.packSize(),
+		Props: map[z7.PROPID]any{
+			z7.PROPID_kpidCRC:    e.CRC,
+			z7.PROPID_kpidMethod: method,
+			kpidLoadFlags:        e.Chunks[0].LoadFlags,
+			kpidTextureFlags:     e.Chunks[0].TextureFlags,
+		},
+	}, nil
+}
+
+func (v *reader) ItemProps() []z7.PROPID {
+	return []z7.PROPID{
+		z7.PROPID_kpidPath,
+		z7.PROPID_kpidSize,
+		z7.PROPID_kpidPackSize,
+		z7.PROPID_kpidCRC,
+		z7.PROPID_kpidMethod,
+	}
+}
+
+func (v *reader) ArcProps() []z7.PROPID {
+	return nil
+}
+
+func (v *reader) ArcProperty(propID z7.PROPID) (any, error) {
+	return nil, nil
+}
+
+// chunkFile opens the file containing the chunks for an archive index.
+func (v *reader) chunkFile(index uint16) (io.ReaderAt, error) {
+	if index == vpkDirIndex {
+		return io.NewSectionReader(v.r, v.data, 1<<63-1-v.data), nil
+	}
+	if r, ok := v.chunks[index]; ok {
+		return r, nil
+	}
+	if v.vol == nil {
+		return nil, z7plugin.ExtractError(z7.NArchive_NExtract_NOperationResult_kUnavailable)
+	}
+	name, ok := vpkChunkName(v.vol.Name(), index)
+	if !ok {
+		return nil, z7plugin.ExtractError(z7.NArchive_NExtract_NOperationResult_kUnavailable)
+	}
+	r, _, err := v.vol.OpenVolume(name)
+	for _, dir := range settings.ChunkPaths {
+		if !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		var f *os.File
+		if f, err = os.Open(filepath.Join(dir, name)); err == nil {
+			v.files = append(v.files, f)
+			r = f
+		}
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, z7plugin.ExtractError(z7.NArchive_NExtract_NOperationResult_kUnavailable)
+		}
+		return nil, err
+	}
+	v.chunks[index] = r
+	return r, nil
+}
+
+func (v *reader) Extract(ctx context.Context, index int, w io.Writer) error {
+	e := v.es[index]
+	h := crc32.NewIEEE()
+	w = io.MultiWriter(w, h)
+	if _, err := w.Write(e.Preload); err != nil {
+		return err
+	}
+	for _, c := range e.Chunks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if c.compressed() {
+			return z7plugin.ExtractError(z7.NArchive_NExtract_NOperationResult_kUnsupportedMethod) // TODO: LZHAM
+		}
+		r, err := v.chunkFile(e.ArchiveIndex)
+		if err != nil {
+			return err
+		}
+		if n, err := io.Copy(w, io.NewSectionReader(r, int64(c.Offset), int64(c.UncompressedSize))); err != nil {
+			return err
+		} else if n != int64(c.UncompressedSize) {
+			return z7plugin.ExtractError(z7.NArchive_NExtract_NOperationResult_kUnexpectedEnd)
+		}
+	}
+	if h.Sum32() != e.CRC {
+		return z7plugin.ExtractError(z7.NArchive_NExtract_NOperationResult_kCRCError)
+	}
+	return nil
+}
+
+// GetStream returns a reader for the contents of an item if it isn't
+// compressed.
+func (v *reader) GetStream(index int) (r io.ReaderAt, size int64, err error) {
+	e := v.es[index]
+	for _, c := range e.Chunks {
+		if c.compressed() {
+			return nil, 0, nil
+		}
+	}
+	f, err := v.chunkFile(e.ArchiveIndex)
+	if err != nil {
+		var ee z7plugin.ExtractError
+		if errors.As(err, &ee) {
+			err = nil
+		}
+		return nil, 0, err
+	}
+	var m multiReaderAt
+	m.add(io.NewSectionReader(bytes.NewReader(e.Preload), 0, int64(len(e.Preload))))
+	for _, c := range e.Chunks {
+		m.add(io.NewSectionReader(f, int64(c.Offset), int64(c.UncompressedSize)))
+	}
+	return &m, m.size, nil
+}
+
+// RawStream returns the chunks of an item as stored in the archive. The info
+// is a vpkEntry with the chunk offsets relative to the start of r.
+func (v *reader) RawStream(index int) (r io.ReaderAt, size int64, info any, err error) {
+	e := v.es[index]
+	f, err := v.chunkFile(e.ArchiveIndex)
+	if err != nil {
+		var ee z7plugin.ExtractError
+		if errors.As(err, &ee) {
+			err = nil
+		}
+		return nil, 0, nil, err
+	}
+	var m multiReaderAt
+	chunks := make([]vpkChunk, len(e.Chunks))
+	for i, c := range e.Chunks {
+		chunks[i] = c
+		chunks[i].Offset = uint64(m.size)
+		m.add(io.NewSectionReader(f, int64(c.Offset), int64(c.CompressedSize)))
+	}
+	e.Chunks = chunks
+	return &m, m.size, e, nil
+}
+
+// multiReaderAt concatenates sections.
+type multiReaderAt struct {
+	rs   []*io.SectionReader
+	size int64
+}
+
+func (m *multiReaderAt) add(r *io.SectionReader) {
+	m.rs = append(m.rs, r)
+	m.size += r.Size()
+}
+
+func (m *multiReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	var n int
+	for _, r := range m.rs {
+		if len(b) == 0 {
+			break
+		}
+		if off >= r.Size() {
+			off -= r.Size()
+			continue
+		}
+		want := min(int64(len(b)), r.Size()-off)
+		x, err := r.ReadAt(b[:want], off)
+		n += x
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+		if int64(x) != want {
+			return n, io.ErrUnexpectedEOF
+		}
+		b, off = b[x:], 0
+	}
+	if len(b) != 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,338 @@
+package tf2vpk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// VPK0203 directory file format, as used by Titanfall and Titanfall 2.
+//
+//	header
+//	  uint32 magic (0x55AA1234)
+//	  uint16 major version (2)
+//	  uint16 minor version (3)
+//	  uint32 tree size
+//	  uint32 signature size (0)
+//	tree
+//	  {ext\0 {path\0 {name\0 entry}... \0}... \0}... \0
+//	entry
+//	  uint32 crc32
+//	  uint16 preload bytes
+//	  uint16 archive index
+//	  {chunk uint16(0x0000)}... chunk uint16(0xFFFF)
+//	  preload data
+//	chunk
+//	  uint32 load flags
+//	  uint16 texture flags
+//	  uint64 offset
+//	  uint64 compressed size
+//	  uint64 uncompressed size
+//
+// Chunks are stored in the file for the archive index (see vpkChunkName), or
+// after the tree if the index is vpkDirIndex. If the compressed size differs
+// from the uncompressed size, the chunk is compressed with LZHAM.
+const (
+	vpkMagic        = 0x55AA1234
+	vpkVersionMajor = 2
+	vpkVersionMinor = 3
+	vpkHeaderSize   = 16
+	vpkDirIndex     = 0x7FFF  // data stored in the directory file
+	vpkChunkSize    = 1 << 20 // maximum uncompressed size of new chunks
+)
+
+// vpkSignature is the start of the header (the magic and version, both
+// little-endian).
+const vpkSignature = "\x34\x12\xaa\x55\x02\x00\x03\x00"
+
+// VPK load flags.
+const (
+	vpkLoadVisible = 1 << 0
+	vpkLoadCache   = 1 << 8
+
+	vpkLoadDefault = vpkLoadVisible | vpkLoadCache
+)
+
+type vpkChunk struct {
+	LoadFlags        uint32
+	TextureFlags     uint16
+	Offset           uint64
+	CompressedSize   uint64
+	UncompressedSize uint64
+}
+
+func (c vpkChunk) compressed() bool {
+	return c.CompressedSize != c.UncompressedSize
+}
+
+// valid checks that the offset and sizes can be used as an int64, and that the
+// end of the chunk doesn't overflow (whether it is in the file is checked when
+// it is read).
+func (c vpkChunk) valid() bool {
+	return c.Offset <= math.MaxInt64 &&
+		c.CompressedSize <= math.MaxInt64-c.Offset &&
+		c.UncompressedSize <= math.MaxInt64
+}
+
+type vpkEntry struct {
+	Path         string // slash-separated
+	CRC          uint32
+	ArchiveIndex uint16
+	Chunks       []vpkChunk
+	Preload      []byte
+}
+
+// size gets the uncompressed size of the entry.
+func (e vpkEntry) size() uint64 {
+	n := uint64(len(e.Preload))
+	for _, c := range e.Chunks {
+		n += c.UncompressedSize
+	}
+	return n
+}
+
+// valid checks that the total sizes of the entry can be used as an int64.
+func (e vpkEntry) valid() bool {
+	size, packSize := uint64(len(e.Preload)), uint64(len(e.Preload))
+	for _, c := range e.Chunks {
+		if !c.valid() {
+			return false
+		}
+		if size += c.UncompressedSize; size > math.MaxInt64 {
+			return false
+		}
+		if packSize += c.CompressedSize; packSize > math.MaxInt64 {
+			return false
+		}
+	}
+	return true
+}
+
+// packSize gets the compressed size of the entry.
+func (e vpkEntry) packSize() uint64 {
+	n := uint64(len(e.Preload))
+	for _, c := range e.Chunks {
+		n += c.CompressedSize
+	}
+	return n
+}
+
+var errVPKFormat = errors.New("vpk: invalid directory file")
+
+// readVPKDir reads the entries from a directory file, returning the offset of
+// the data stored in it.
+func readVPKDir(r io.ReaderAt, size int64) ([]vpkEntry, int64, error) {
+	var hdr [vpkHeaderSize]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		if err == io.EOF {
+			err = errVPKFormat
+		}
+		return nil, 0, err
+	}
+	if binary.LittleEndian.Uint32(hdr[0:]) != vpkMagic {
+		return nil, 0, errVPKFormat
+	}
+	if major, minor := binary.LittleEndian.Uint16(hdr[4:]), binary.LittleEndian.Uint16(hdr[6:]); major != vpkVersionMajor || minor != vpkVersionMinor {
+		return nil, 0, fmt.Errorf("vpk: unsupported version %d.%d", major, minor)
+	}
+	treeSize := int64(binary.LittleEndian.Uint32(hdr[8:]))
+	if vpkHeaderSize+treeSize > size {
+		return nil, 0, errVPKFormat
+	}
+	tree := make([]byte, treeSize)
+	if _, err := r.ReadAt(tree, vpkHeaderSize); err != nil {
+		return nil, 0, err
+	}
+	es, err := parseVPKTree(tree)
+	if err != nil {
+		return nil, 0, err
+	}
+	return es, vpkHeaderSize + treeSize, nil
+}
+
+func parseVPKTree(b []byte) ([]vpkEntry, error) {
+	str := func() (string, error) {
+		i := bytes.IndexByte(b, 0)
+		if i == -1 {
+			return "", errVPKFormat
+		}
+		s := string(b[:i])
+		b = b[i+1:]
+		return s, nil
+	}
+	var es []vpkEntry
+	for {
+		ext, err := str()
+		if err != nil || ext == "" {
+			return es, err
+		}
+		for {
+			dir, err := str()
+			if err != nil {
+				return nil, err
+			}
+			if dir == "" {
+				break
+			}
+			for {
+				name, err := str()
+				if err != nil {
+					return nil, err
+				}
+				if name == "" {
+					break
+				}
+				e := vpkEntry{Path: name}
+				if ext != " " {
+					e.Path += "." + ext
+				}
+				if dir != " " {
+					e.Path = dir + "/" + e.Path
+				}
+				if len(b) < 8 {
+					return nil, errVPKFormat
+				}
+				e.CRC = binary.LittleEndian.Uint32(b[0:])
+				preload := int(binary.LittleEndian.Uint16(b[4:]))
+				e.ArchiveIndex = binary.LittleEndian.Uint16(b[6:])
+				b = b[8:]
+				for {
+					if len(b) < 32 {
+						return nil, errVPKFormat
+					}
+					c := vpkChunk{
+						LoadFlags:        binary.LittleEndian.Uint32(b[0:]),
+						TextureFlags:     binary.LittleEndian.Uint16(b[4:]),
+						Offset:           binary.LittleEndian.Uint64(b[6:]),
+						CompressedSize:   binary.LittleEndian.Uint64(b[14:]),
+						UncompressedSize: binary.LittleEndian.Uint64(b[22:]),
+					}
+					e.Chunks = append(e.Chunks, c)
+					term := binary.LittleEndian.Uint16(b[30:])
+					b = b[32:]
+					if term == 0xFFFF {
+						break
+					}
+					if term != 0 {
+						return nil, errVPKFormat
+					}
+				}
+				if len(b) < preload {
+					return nil, errVPKFormat
+				}
+				if preload != 0 {
+					e.Preload = bytes.Clone(b[:preload])
+				}
+				b = b[preload:]
+				if !e.valid() {
+					return nil, errVPKFormat
+				}
+				es = append(es, e)
+			}
+		}
+	}
+}
+
+// appendVPKTree encodes the tree for es. Entries are grouped by extension, then
+// by directory, in the order they first appear.
+func appendVPKTree(b []byte, es []vpkEntry) ([]byte, error) {
+	type group struct {
+		key string
+		es  []vpkEntry
+	}
+	var exts []*group
+	dirs := map[string][]*group{}
+	for _, e := range es {
+		dir, name := " ", e.Path
+		if i := strings.LastIndexByte(name, '/'); i != -1 {
+			dir, name = name[:i], name[i+1:]
+		}
+		ext := " "
+		if i := strings.LastIndexByte(name, '.'); i > 0 && i < len(name)-1 {
+			name, ext = name[:i], name[i+1:]
+		}
+		if name == "" || dir == "" || strings.ContainsRune(e.Path, 0) {
+			return b, fmt.Errorf("vpk: invalid path %q", e.Path)
+		}
+		if len(e.Preload) > 0xFFFF || len(e.Chunks) == 0 {
+			return b, fmt.Errorf("vpk: invalid entry %q", e.Path)
+		}
+		e.Path = name
+		gs, ok := dirs[ext]
+		if !ok {
+			exts = append(exts, &group{key: ext})
+		}
+		var g *group
+		for _, x := range gs {
+			if x.key == dir {
+				g = x
+				break
+			}
+		}
+		if g == nil {
+			g = &group{key: dir}
+			dirs[ext] = append(gs, g)
+		}
+		g.es = append(g.es, e)
+	}
+	for _, ext := range exts {
+		b = append(append(b, ext.key...), 0)
+		for _, dir := range dirs[ext.key] {
+			b = append(append(b, dir.key...), 0)
+			for _, e := range dir.es {
+				b = append(append(b, e.Path...), 0)
+				b = binary.LittleEndian.AppendUint32(b, e.CRC)
+				b = binary.LittleEndian.AppendUint16(b, uint16(len(e.Preload)))
+				b = binary.LittleEndian.AppendUint16(b, e.ArchiveIndex)
+				for i, c := range e.Chunks {
+					b = binary.LittleEndian.AppendUint32(b, c.LoadFlags)
+					b = binary.LittleEndian.AppendUint16(b, c.TextureFlags)
+					b = binary.LittleEndian.AppendUint64(b, c.Offset)
+					b = binary.LittleEndian.AppendUint64(b, c.CompressedSize)
+					b = binary.LittleEndian.AppendUint64(b, c.UncompressedSize)
+					if i == len(e.Chunks)-1 {
+						b = binary.LittleEndian.AppendUint16(b, 0xFFFF)
+					} else {
+						b = binary.LittleEndian.AppendUint16(b, 0)
+					}
+				}
+				b = append(b, e.Preload...)
+			}
+			b = append(b, 0)
+		}
+		b = append(b, 0)
+	}
+	return append(b, 0), nil
+}
+
+// appendVPKHeader encodes the header for a tree of the specified size.
+func appendVPKHeader(b []byte, treeSize int) []byte {
+	b = binary.LittleEndian.AppendUint32(b, vpkMagic)
+	b = binary.LittleEndian.AppendUint16(b, vpkVersionMajor)
+	b = binary.LittleEndian.AppendUint16(b, vpkVersionMinor)
+	b = binary.LittleEndian.AppendUint32(b, uint32(treeSize))
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	return b
+}
+
+// vpkChunkName gets the name of the file containing the chunks for an archive
+// index from the name of the directory file. For example,
+// "englishclient_mp_box.bsp.pak000_dir.vpk" becomes
+// "client_mp_box.bsp.pak000_003.vpk" for index 3.
+func vpkChunkName(dirName string, index uint16) (string, bool) {
+	base, ok := strings.CutSuffix(dirName, "_dir.vpk")
+	if !ok {
+		return "", false
+	}
+	for _, s := range []string{"client", "server"} {
+		if i := strings.Index(base, s); i != -1 && !strings.ContainsRune(base[:i], '_') {
+			base = base[i:]
+			break
+		}
+	}
+	return fmt.Sprintf("%s_%03d.vpk", base, index), true
+}
@@ -0,0 +1,98 @@
+package tf2vpk
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestVPKSignature(t *testing.T) {
+	tree, err := appendVPKTree(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := append(appendVPKHeader(nil, len(tree)), tree...)
+	if !bytes.HasPrefix(b, []byte(vpkSignature)) {
+		t.Errorf("header %x does not start with signature %x", b[:vpkHeaderSize], vpkSignature)
+	}
+	if _, _, err := readVPKDir(bytes.NewReader(b), int64(len(b))); err != nil {
+		t.Errorf("read empty directory: %v", err)
+	}
+}
+
+func TestVPKTreeRoundTrip(t *testing.T) {
+	es := []vpkEntry{
+		{Path: "scripts/vscripts/a.nut", CRC: 1, ArchiveIndex: 0, Chunks: []vpkChunk{{LoadFlags: vpkLoadDefault, Offset: 0, CompressedSize: 10, UncompressedSize: 10}}},
+		{Path: "scripts/vscripts/b.nut", CRC: 2, ArchiveIndex: 0, Preload: []byte("pre"), Chunks: []vpkChunk{{LoadFlags: vpkLoadDefault, Offset: 10, CompressedSize: 5, UncompressedSize: 5}}},
+		{Path: "materials/x.vtf", CRC: 3, ArchiveIndex: 3, Chunks: []vpkChunk{{TextureFlags: 8, Offset: 0, CompressedSize: 4, UncompressedSize: 9}, {TextureFlags: 8, Offset: 4, CompressedSize: 2, UncompressedSize: 2}}},
+		{Path: "readme", CRC: 4, ArchiveIndex: vpkDirIndex, Chunks: []vpkChunk{{Offset: 7}}},
+		{Path: "scripts/c.txt", CRC: 5, ArchiveIndex: 1, Chunks: []vpkChunk{{LoadFlags: 1, Offset: 1 << 40, CompressedSize: 1, UncompressedSize: 1}}},
+	}
+	tree, err := appendVPKTree(nil, es)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseVPKTree(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, es) {
+		t.Errorf("round trip:\ngot  %+v\nwant %+v", got, es)
+	}
+
+	// entries are grouped by extension, then by directory
+	got, err = parseVPKTree(must(appendVPKTree(nil, []vpkEntry{es[0], es[2], es[4], es[1]})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []vpkEntry{es[0], es[1], es[2], es[4]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("grouping:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	for _, e := range []vpkEntry{
+		{Path: "", Chunks: []vpkChunk{{}}},
+		{Path: "a/", Chunks: []vpkChunk{{}}},
+		{Path: "a\x00b", Chunks: []vpkChunk{{}}},
+		{Path: "a"},
+		{Path: "a", Preload: make([]byte, 0x10000), Chunks: []vpkChunk{{}}},
+	} {
+		if _, err := appendVPKTree(nil, []vpkEntry{e}); err == nil {
+			t.Errorf("expected error for entry %q (%d chunks, %d preload)", e.Path, len(e.Chunks), len(e.Preload))
+		}
+	}
+	for _, b := range [][]byte{
+		tree[:len(tree)-1],
+		tree[:len(tree)/2],
+		[]byte("ext\x00dir\x00name\x00\x00"),
+	} {
+		if _, err := parseVPKTree(b); err != errVPKFormat {
+			t.Errorf("parse truncated tree: expected errVPKFormat, got %v", err)
+		}
+	}
+
+	// offsets and sizes must fit in an int64
+	for _, cs := range [][]vpkChunk{
+		{{Offset: 1 << 63}},
+		{{CompressedSize: 1 << 63, UncompressedSize: 1}},
+		{{UncompressedSize: 1 << 63}},
+		{{Offset: math.MaxInt64, CompressedSize: 1, UncompressedSize: 1}},
+		{{Offset: 1 << 62, CompressedSize: 1 << 62, UncompressedSize: 1 << 62}},
+		{{UncompressedSize: 1 << 62}, {UncompressedSize: 1 << 62}},
+		{{CompressedSize: 1 << 62}, {Offset: 1, CompressedSize: 1 << 62}},
+	} {
+		if _, err := parseVPKTree(must(appendVPKTree(nil, []vpkEntry{{Path: "a", Chunks: cs}}))); err != errVPKFormat {
+			t.Errorf("parse chunks %+v: expected errVPKFormat, got %v", cs, err)
+		}
+	}
+	if _, err := parseVPKTree(must(appendVPKTree(nil, []vpkEntry{{Path: "a", Chunks: []vpkChunk{{Offset: math.MaxInt64 - 1, CompressedSize: 1, UncompressedSize: math.MaxInt64}}}}))); err != nil {
+		t.Errorf("parse chunk at the maximum offset: %v", err)
+	}
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
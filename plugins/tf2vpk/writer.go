@@ -0,0 +1,269 @@
+package tf2vpk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+
+	"github.com/pg9182/7zplugin/z7plugin"
+)
+
+// writer writes a VPK directory file and its chunk files.
+//
+// The chunk files are created as volumes (IArchiveUpdateCallback2), with the
+// volume index being the archive index. Unmodified items are copied as-is
+// (including LZHAM-compressed chunks and their flags) to the chunk file with
+// the same archive index they had, and new data is stored in chunk file 0. If
+// volumes can't be created, all data is stored in the directory file after the
+// tree (archive index 0x7FFF) instead.
+//
+// The following are NOT supported:
+//
+//   - Naming the chunk files. 7-Zip names volumes itself by numbering them
+//     after the archive (GetVolumeStream only takes an index, and handlers
+//     aren't given the archive path), so the chunk files are not named
+//     according to vpkChunkName, and must be renamed before the archive can be
+//     opened again.
+//   - LZHAM compression. There is no LZHAM encoder, so new data is always
+//     stored uncompressed.
+type writer struct{}
+
+// writerItem is an item to be written.
+type writerItem struct {
+	z7plugin.UpdateItem
+	raw bool
+	e   vpkEntry
+}
+
+func (v writer) Update(ctx context.Context, w io.Writer, items iter.Seq[z7plugin.UpdateItem]) error {
+	return v.UpdateVolumes(ctx, w, nil, items)
+}
+
+func (writer) UpdateVolumes(ctx context.Context, w io.Writer, vol z7plugin.OutVolumes, items iter.Seq[z7plugin.UpdateItem]) error {
+	ws, ok := w.(io.WriteSeeker)
+	if !ok {
+		return errors.New("vpk: output must be seekable")
+	}
+
+	// plan the tree so space can be reserved for it
+	var wis []writerItem
+	for it := range items {
+		if it.Props.IsDir || it.Props.IsAltStream {
+			continue
+		}
+		wi := writerItem{UpdateItem: it}
+		wi.e.Path = it.Props.Path
+		if r, info, err := it.OpenRaw(); err == nil {
+			r.Close()
+			wi.raw = true
+			wi.e.ArchiveIndex = info.(vpkEntry).ArchiveIndex
+			wi.e.Chunks = info.(vpkEntry).Chunks
+			wi.e.Preload = info.(vpkEntry).Preload
+		} else if err != z7plugin.ErrNoRaw {
+			return err
+		} else {
+			c := vpkChunk{LoadFlags: vpkLoadDefault}
+			if x, ok := it.Existing.Props[kpidLoadFlags].(uint32); ok {
+				c.LoadFlags = x
+			}
+			if x, ok := it.Existing.Props[kpidTextureFlags].(uint16); ok {
+				c.TextureFlags = x
+			}
+			n := max(1, (it.Props.Size+vpkChunkSize-1)/vpkChunkSize)
+			for range n {
+				wi.e.Chunks = append(wi.e.Chunks, c)
+			}
+		}
+		wis = append(wis, wi)
+	}
+	es := make([]vpkEntry, len(wis))
+	for i, wi := range wis {
+		es[i] = wi.e
+	}
+	tree, err := appendVPKTree(nil, es)
+	if err != nil {
+		return err
+	}
+	reserved := len(tree)
+
+	// write the data
+	if _, err := ws.Seek(vpkHeaderSize+int64(reserved), io.SeekStart); err != nil {
+		return err
+	}
+	out := &vpkOutput{
+		dir:    ws,
+		vol:    vol,
+		chunks: map[uint16]*vpkChunkOutput{},
+	}
+	if err := out.write(ctx, wis, es[:0]); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	es = out.es
+
+	// write the header and tree
+	if tree, err = appendVPKTree(appendVPKHeader(nil, reserved), es); err != nil {
+		return err
+	}
+	if len(tree) > vpkHeaderSize+reserved {
+		return fmt.Errorf("vpk: tree size changed while updating")
+	}
+	tree = append(tree, make([]byte, vpkHeaderSize+reserved-len(tree))...)
+	if _, err := ws.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := ws.Write(tree); err != nil {
+		return err
+	}
+	return nil
+}
+
+// vpkOutput writes chunks to the directory file or the chunk files.
+type vpkOutput struct {
+	dir     io.Writer
+	dirPos  uint64 // relative to the end of the tree
+	dirOnly bool   // volumes are not supported
+	vol     z7plugin.OutVolumes
+	chunks  map[uint16]*vpkChunkOutput
+	es      []vpkEntry // the entries written so far
+}
+
+// vpkChunkOutput is an open chunk file.
+type vpkChunkOutput struct {
+	w   io.WriteCloser
+	pos uint64
+}
+
+// output gets the writer and current offset for the chunks of an archive
+// index, returning the archive index actually used.
+func (o *vpkOutput) output(index uint16) (io.Writer, *uint64, uint16, error) {
+	if index == vpkDirIndex || o.dirOnly {
+		return o.dir, &o.dirPos, vpkDirIndex, nil
+	}
+	if c, ok := o.chunks[index]; ok {
+		return c.w, &c.pos, index, nil
+	}
+	if o.vol == nil {
+		o.dirOnly = true
+		return o.dir, &o.dirPos, vpkDirIndex, nil
+	}
+	w, err := o.vol.CreateVolume(int(index))
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) && len(o.chunks) == 0 {
+			o.dirOnly = true
+			return o.dir, &o.dirPos, vpkDirIndex, nil
+		}
+		return nil, nil, 0, err
+	}
+	c := &vpkChunkOutput{w: w}
+	o.chunks[index] = c
+	return c.w, &c.pos, index, nil
+}
+
+// write writes the data for wis, appending the entries to es.
+func (o *vpkOutput) write(ctx context.Context, wis []writerItem, es []vpkEntry) error {
+	var buf []byte
+	for _, wi := range wis {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if wi.raw {
+			r, info, err := wi.OpenRaw()
+			if err != nil {
+				return err
+			}
+			w, pos, index, err := o.output(wi.e.ArchiveIndex)
+			if err != nil {
+				r.Close()
+				return err
+			}
+			n, err := io.Copy(w, r)
+			r.Close()
+			if err != nil {
+				return err
+			}
+			wi.e.CRC = info.(vpkEntry).CRC
+			wi.e.ArchiveIndex = index
+			for i := range wi.e.Chunks {
+				wi.e.Chunks[i].Offset += *pos
+			}
+			*pos += uint64(n)
+			es = append(es, wi.e)
+			continue
+		}
+
+		r, err := wi.Open()
+		if err != nil {
+			if err == z7plugin.ErrUnavailable {
+				continue
+			}
+			return err
+		}
+		w, pos, index, err := o.output(0)
+		if err != nil {
+			r.Close()
+			return err
+		}
+		if buf == nil {
+			buf = make([]byte, vpkChunkSize)
+		}
+		var (
+			h      = crc32.NewIEEE()
+			chunks = wi.e.Chunks[:0]
+			c      = wi.e.Chunks[0]
+			size   uint64
+		)
+		for {
+			n, err := io.ReadFull(r, buf)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				r.Close()
+				return err
+			}
+			if n != 0 || len(chunks) == 0 {
+				if _, err := w.Write(buf[:n]); err != nil {
+					r.Close()
+					return err
+				}
+				h.Write(buf[:n])
+				c.Offset = *pos
+				c.CompressedSize = uint64(n)
+				c.UncompressedSize = uint64(n)
+				chunks = append(chunks, c)
+				*pos += uint64(n)
+				size += uint64(n)
+			}
+			if err != nil {
+				break
+			}
+		}
+		if err := r.Close(); err != nil {
+			return err
+		}
+		if size != wi.Props.Size {
+			// the space for the tree was reserved for the chunks in Props.Size
+			return fmt.Errorf("vpk: %s: read %d bytes, but the size is %d", wi.e.Path, size, wi.Props.Size)
+		}
+		wi.e.CRC = h.Sum32()
+		wi.e.ArchiveIndex = index
+		wi.e.Chunks = chunks
+		es = append(es, wi.e)
+	}
+	o.es = es
+	return nil
+}
+
+// Close closes the chunk files.
+func (o *vpkOutput) Close() error {
+	var errs []error
+	for _, c := range o.chunks {
+		errs = append(errs, c.w.Close())
+	}
+	o.chunks = nil
+	return errors.Join(errs...)
+}
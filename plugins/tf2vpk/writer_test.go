@@ -0,0 +1,212 @@
+package tf2vpk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pg9182/7zplugin/z7plugin"
+)
+
+const testDirName = "englishclient_test.bsp.pak000_dir.vpk"
+
+// testVolumes stores volumes in memory, and implements both z7plugin.Volumes
+// and z7plugin.OutVolumes.
+type testVolumes struct {
+	dir    []byte
+	chunks map[int]*bytes.Buffer // nil if volumes aren't supported
+}
+
+func (v *testVolumes) Name() string {
+	return testDirName
+}
+
+func (v *testVolumes) OpenVolume(name string) (io.ReaderAt, int64, error) {
+	for i, b := range v.chunks {
+		if n, _ := vpkChunkName(testDirName, uint16(i)); n == name {
+			return bytes.NewReader(b.Bytes()), int64(b.Len()), nil
+		}
+	}
+	return nil, 0, fs.ErrNotExist
+}
+
+func (v *testVolumes) CreateVolume(index int) (io.WriteCloser, error) {
+	if v.chunks == nil {
+		return nil, errors.ErrUnsupported
+	}
+	if _, ok := v.chunks[index]; ok {
+		return nil, fs.ErrExist
+	}
+	b := new(bytes.Buffer)
+	v.chunks[index] = b
+	return nopWriteCloser{b}, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+type nopProgress struct{}
+
+func (nopProgress) SetTotal(files, bytes uint64) error     { return nil }
+func (nopProgress) SetCompleted(files, bytes uint64) error { return nil }
+
+// update writes an archive with items, storing the directory file in v.dir.
+func (v *testVolumes) update(t *testing.T, items []z7plugin.UpdateItem) {
+	t.Helper()
+	f, err := os.Create(filepath.Join(t.TempDir(), testDirName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := (writer{}).UpdateVolumes(context.Background(), f, v, slices.Values(items)); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if v.dir, err = os.ReadFile(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// open opens the archive written by update.
+func (v *testVolumes) open(t *testing.T) *reader {
+	t.Helper()
+	r := new(reader)
+	if err := r.OpenVolumes(context.Background(), bytes.NewReader(v.dir), int64(len(v.dir)), v, nopProgress{}); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return r
+}
+
+// contents extracts every item from r.
+func contents(t *testing.T, r *reader) map[string]string {
+	t.Helper()
+	m := map[string]string{}
+	for i := range r.NumItems() {
+		it, err := r.Item(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := r.Extract(context.Background(), i, &b); err != nil {
+			t.Errorf("extract %q: %v", it.Path, err)
+		}
+		if it.Size != uint64(b.Len()) {
+			t.Errorf("extract %q: got %d bytes, size is %d", it.Path, b.Len(), it.Size)
+		}
+		m[it.Path] = b.String()
+	}
+	return m
+}
+
+func newItem(index int, path, data string) z7plugin.UpdateItem {
+	return z7plugin.NewUpdateItem(index, z7plugin.Item{Path: path, Size: uint64(len(data))}, func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(data)), nil
+	})
+}
+
+func TestWriter(t *testing.T) {
+	want := map[string]string{
+		"scripts/vscripts/a.nut": "print(1)",
+		"scripts/vscripts/b.nut": strings.Repeat("x", vpkChunkSize+10), // multiple chunks
+		"materials/empty.vmt":    "",
+		"readme":                 "hello, world",
+	}
+	var items []z7plugin.UpdateItem
+	for _, p := range slices.Sorted(maps.Keys(want)) {
+		items = append(items, newItem(len(items), p, want[p]))
+	}
+
+	for _, volumes := range []bool{true, false} {
+		t.Run(fmt.Sprintf("volumes=%t", volumes), func(t *testing.T) {
+			v := &testVolumes{}
+			if volumes {
+				v.chunks = map[int]*bytes.Buffer{}
+			}
+			v.update(t, items)
+
+			r := v.open(t)
+			defer r.Close()
+			idx := uint16(vpkDirIndex)
+			if volumes {
+				idx = 0
+			}
+			for _, e := range r.es {
+				if e.ArchiveIndex != idx {
+					t.Errorf("%q: archive index %d, expected %d", e.Path, e.ArchiveIndex, idx)
+				}
+			}
+			if volumes && len(v.chunks) != 1 {
+				t.Errorf("expected 1 chunk file, got %d", len(v.chunks))
+			}
+			if got := contents(t, r); !maps.Equal(got, want) {
+				t.Errorf("contents differ")
+			}
+
+			// keep every item but one, and add a new one (the kept items
+			// should be copied without being extracted)
+			var kept []z7plugin.UpdateItem
+			for i := range r.NumItems() {
+				it, err := z7plugin.KeepUpdateItem(context.Background(), len(kept), r, i)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if it.Props.Path == "readme" {
+					continue
+				}
+				if _, _, err := it.OpenRaw(); err != nil {
+					t.Errorf("open raw %q: %v", it.Props.Path, err)
+				}
+				kept = append(kept, it)
+			}
+			kept = append(kept, newItem(len(kept), "new.txt", "new"))
+
+			v2 := &testVolumes{}
+			if volumes {
+				v2.chunks = map[int]*bytes.Buffer{}
+			}
+			v2.update(t, kept)
+
+			r2 := v2.open(t)
+			defer r2.Close()
+			want2 := map[string]string{"new.txt": "new"}
+			for p, s := range want {
+				if p != "readme" {
+					want2[p] = s
+				}
+			}
+			if got := contents(t, r2); !maps.Equal(got, want2) {
+				t.Errorf("contents differ after update")
+			}
+		})
+	}
+}
+
+func TestWriterSizeMismatch(t *testing.T) {
+	for _, size := range []uint64{0, 4, 6, vpkChunkSize + 1} {
+		it := z7plugin.NewUpdateItem(0, z7plugin.Item{Path: "a.txt", Size: size}, func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("hello")), nil
+		})
+		f, err := os.Create(filepath.Join(t.TempDir(), testDirName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = (writer{}).Update(context.Background(), f, slices.Values([]z7plugin.UpdateItem{it}))
+		f.Close()
+		if err == nil || !strings.Contains(err.Error(), "read 5 bytes") {
+			t.Errorf("size %d: expected size mismatch error, got %v", size, err)
+		}
+	}
+}
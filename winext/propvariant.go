@@ -0,0 +1,63 @@
+//go:build windows
+
+package winext
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/lxn/win"
+	"golang.org/x/sys/windows"
+)
+
+// propVariant is the memory layout of the start of a PROPVARIANT.
+type propVariant struct {
+	vt         win.VARTYPE
+	wReserved1 uint16
+	wReserved2 uint16
+	wReserved3 uint16
+	val        [8]byte
+}
+
+func propVariantOf(v *PROPVARIANT) *propVariant {
+	return (*propVariant)(unsafe.Pointer(v))
+}
+
+func PropVariantSetUInt64(v *PROPVARIANT, x uint64) {
+	p := propVariantOf(v)
+	p.vt = win.VT_UI8
+	*(*uint64)(unsafe.Pointer(&p.val)) = x
+}
+
+func PropVariantSetInt64(v *PROPVARIANT, x int64) {
+	p := propVariantOf(v)
+	p.vt = win.VT_I8
+	*(*int64)(unsafe.Pointer(&p.val)) = x
+}
+
+// PropVariantSetFiletime sets v to a VT_FILETIME with the specified 7-Zip
+// precision (k_PropVar_TimePrec_*) and extra nanoseconds (0-99).
+func PropVariantSetFiletime(v *PROPVARIANT, ft windows.Filetime, prec uint16, ns uint16) {
+	p := propVariantOf(v)
+	p.vt = win.VT_FILETIME
+	p.wReserved1 = prec
+	p.wReserved2 = ns
+	*(*windows.Filetime)(unsafe.Pointer(&p.val)) = ft
+}
+
+// PropVariantUInt64 gets the value of a VT_UI8 or VT_I8.
+func PropVariantUInt64(v *PROPVARIANT) uint64 {
+	return *(*uint64)(unsafe.Pointer(&propVariantOf(v).val))
+}
+
+// PropVariantFiletime gets the value of a VT_FILETIME, along with the 7-Zip
+// precision (k_PropVar_TimePrec_*) and extra nanoseconds (0-99).
+func PropVariantFiletime(v *PROPVARIANT) (ft windows.Filetime, prec uint16, ns uint16) {
+	p := propVariantOf(v)
+	return *(*windows.Filetime)(unsafe.Pointer(&p.val)), p.wReserved1, p.wReserved2
+}
+
+// PropVariantClear frees the value of v and sets it to VT_EMPTY.
+func PropVariantClear(v *PROPVARIANT) {
+	syscall.Syscall(propVariantClear.Addr(), 1, uintptr(unsafe.Pointer(v)), 0, 0)
+}
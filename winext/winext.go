@@ -1,3 +1,5 @@
+//go:build windows
+
 // Package winext is like github.com/lxn/win, but has some additional functions.
 package winext
 
@@ -14,11 +16,24 @@ type PROPVARIANT = win.VARIANT // TODO: is this the same as PROPVARIANT internal
 type HRESULT = uint32          // note: actually an int32, but the constants in the win pkg are untyped and overflow its HRESULT...
 
 var (
+	libkernel32 = windows.NewLazySystemDLL("kernel32.dll")
+	libole32    = windows.NewLazySystemDLL("ole32.dll")
 	liboleaut32 = windows.NewLazySystemDLL("oleaut32.dll")
 
+	outputDebugString     = libkernel32.NewProc("OutputDebugStringW")
+	propVariantClear      = libole32.NewProc("PropVariantClear")
 	sysAllocStringByteLen = liboleaut32.NewProc("SysAllocStringByteLen")
 )
 
+// OutputDebugString sends a string to the debugger (or DebugView).
+func OutputDebugString(s string) {
+	p, err := windows.UTF16PtrFromString(s)
+	if err != nil {
+		return
+	}
+	syscall.SyscallN(outputDebugString.Addr(), uintptr(unsafe.Pointer(p)))
+}
+
 func SysAllocStringByteLen(b []byte) *uint16 /*BSTR*/ {
 	ret, _, _ := syscall.Syscall(sysAllocStringByteLen.Addr(), 1,
 		uintptr(unsafe.Pointer((*uint16)(unsafe.Pointer(unsafe.SliceData(b))))),
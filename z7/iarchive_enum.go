@@ -0,0 +1,132 @@
+package z7
+
+// CPP/7zip/Archive/IArchive.h
+
+type NArchive_NHandlerPropID = uint32
+
+const (
+	NArchive_NHandlerPropID_kName            NArchive_NHandlerPropID = iota // VT_BSTR
+	NArchive_NHandlerPropID_kClassID                                        // binary GUID in VT_BSTR
+	NArchive_NHandlerPropID_kExtension                                      // VT_BSTR
+	NArchive_NHandlerPropID_kAddExtension                                   // VT_BSTR
+	NArchive_NHandlerPropID_kUpdate                                         // VT_BOOL
+	NArchive_NHandlerPropID_kKeepName                                       // VT_BOOL
+	NArchive_NHandlerPropID_kSignature                                      // binary in VT_BSTR
+	NArchive_NHandlerPropID_kMultiSignature                                 // binary in VT_BSTR
+	NArchive_NHandlerPropID_kSignatureOffset                                // VT_UI4
+	NArchive_NHandlerPropID_kAltStreams                                     // VT_BOOL
+	NArchive_NHandlerPropID_kNtSecure                                       // VT_BOOL
+	NArchive_NHandlerPropID_kFlags                                          // VT_UI4
+	NArchive_NHandlerPropID_kTimeFlags                                      // VT_UI4
+)
+
+type NArchive_NArcInfoFlags uint32
+
+const (
+	NArchive_NArcInfoFlags_kKeepName        NArchive_NArcInfoFlags = 1 << 0  // keep name of file in archive name
+	NArchive_NArcInfoFlags_kAltStreams      NArchive_NArcInfoFlags = 1 << 1  // the handler supports alt streams
+	NArchive_NArcInfoFlags_kNtSecure        NArchive_NArcInfoFlags = 1 << 2  // the handler supports NT security
+	NArchive_NArcInfoFlags_kFindSignature   NArchive_NArcInfoFlags = 1 << 3  // the handler can find start of archive
+	NArchive_NArcInfoFlags_kMultiSignature  NArchive_NArcInfoFlags = 1 << 4  // there are several signatures
+	NArchive_NArcInfoFlags_kUseGlobalOffset NArchive_NArcInfoFlags = 1 << 5  // the seek position of stream must be set as global offset
+	NArchive_NArcInfoFlags_kStartOpen       NArchive_NArcInfoFlags = 1 << 6  // call handler for each start position
+	NArchive_NArcInfoFlags_kPureStartOpen   NArchive_NArcInfoFlags = 1 << 7  // call handler only for start of file
+	NArchive_NArcInfoFlags_kBackwardOpen    NArchive_NArcInfoFlags = 1 << 8  // archive can be open backward
+	NArchive_NArcInfoFlags_kPreArc          NArchive_NArcInfoFlags = 1 << 9  // such archive can be stored before real archive (like SFX stub)
+	NArchive_NArcInfoFlags_kSymLinks        NArchive_NArcInfoFlags = 1 << 10 // the handler supports symbolic links
+	NArchive_NArcInfoFlags_kHardLinks       NArchive_NArcInfoFlags = 1 << 11 // the handler supports hard links
+	NArchive_NArcInfoFlags_kByExtOnlyOpen   NArchive_NArcInfoFlags = 1 << 12 // call handler only if file extension matches
+	NArchive_NArcInfoFlags_kHashHandler     NArchive_NArcInfoFlags = 1 << 13 // the handler contains the hashes (checksums)
+	NArchive_NArcInfoFlags_kCTime           NArchive_NArcInfoFlags = 1 << 14
+	NArchive_NArcInfoFlags_kCTime_Default   NArchive_NArcInfoFlags = 1 << 15
+	NArchive_NArcInfoFlags_kATime           NArchive_NArcInfoFlags = 1 << 16
+	NArchive_NArcInfoFlags_kATime_Default   NArchive_NArcInfoFlags = 1 << 17
+	NArchive_NArcInfoFlags_kMTime           NArchive_NArcInfoFlags = 1 << 18
+	NArchive_NArcInfoFlags_kMTime_Default   NArchive_NArcInfoFlags = 1 << 19
+)
+
+type NArchive_k_IsArc_Res uint32
+
+const (
+	NArchive_k_IsArc_Res_NO        NArchive_k_IsArc_Res = 0
+	NArchive_k_IsArc_Res_YES       NArchive_k_IsArc_Res = 1
+	NArchive_k_IsArc_Res_NEED_MORE NArchive_k_IsArc_Res = 2
+)
+
+type NArchive_NExtract_NAskMode = int32
+
+const (
+	NArchive_NExtract_NAskMode_kExtract      NArchive_NExtract_NAskMode = iota
+	NArchive_NExtract_NAskMode_kTest                                    // the data is read, but not written anywhere
+	NArchive_NExtract_NAskMode_kSkip                                    // the item is skipped
+	NArchive_NExtract_NAskMode_kReadExternal                            // the data is read for an external consumer (e.g., a nested archive)
+)
+
+type NArchive_NExtract_NOperationResult = int32
+
+const (
+	NArchive_NExtract_NOperationResult_kOK NArchive_NExtract_NOperationResult = iota
+	NArchive_NExtract_NOperationResult_kUnsupportedMethod
+	NArchive_NExtract_NOperationResult_kDataError
+	NArchive_NExtract_NOperationResult_kCRCError
+	NArchive_NExtract_NOperationResult_kUnavailable
+	NArchive_NExtract_NOperationResult_kUnexpectedEnd
+	NArchive_NExtract_NOperationResult_kDataAfterEnd
+	NArchive_NExtract_NOperationResult_kIsNotArc
+	NArchive_NExtract_NOperationResult_kHeadersError
+	NArchive_NExtract_NOperationResult_kWrongPassword
+)
+
+type NParentType = uint32
+
+const (
+	NParentType_kDir       NParentType = iota
+	NParentType_kAltStream             // the item is an alternate stream of the parent
+)
+
+type NPropDataType = uint32
+
+const (
+	NPropDataType_kMask_ZeroEnd NPropDataType = 1 << 4
+	NPropDataType_kMask_Utf     NPropDataType = 1 << 6
+	NPropDataType_kMask_Utf8    NPropDataType = NPropDataType_kMask_Utf | 0
+	NPropDataType_kMask_Utf16   NPropDataType = NPropDataType_kMask_Utf | 1
+
+	NPropDataType_kNotDefined NPropDataType = 0
+	NPropDataType_kRaw        NPropDataType = 1
+
+	NPropDataType_kUtf8z  NPropDataType = NPropDataType_kMask_Utf8 | NPropDataType_kMask_ZeroEnd
+	NPropDataType_kUtf16z NPropDataType = NPropDataType_kMask_Utf16 | NPropDataType_kMask_ZeroEnd
+)
+
+type NFileTimeType = int32
+
+const (
+	NFileTimeType_kNotDefined NFileTimeType = iota - 1
+	NFileTimeType_kWindows
+	NFileTimeType_kUnix
+	NFileTimeType_kDOS
+	NFileTimeType_k1ns
+)
+
+const (
+	NArchive_NArcInfoTimeFlags_kTime_Prec_Mask_bit_index    = 0
+	NArchive_NArcInfoTimeFlags_kTime_Prec_Mask_num_bits     = 26
+	NArchive_NArcInfoTimeFlags_kTime_Prec_Default_bit_index = 27
+	NArchive_NArcInfoTimeFlags_kTime_Prec_Default_num_bits  = 5
+)
+
+func TIME_PREC_TO_ARC_FLAGS_MASK(v uint32) uint32 {
+	return 1 << (NArchive_NArcInfoTimeFlags_kTime_Prec_Mask_bit_index + v)
+}
+
+func TIME_PREC_TO_ARC_FLAGS_TIME_DEFAULT(v uint32) uint32 {
+	return v << NArchive_NArcInfoTimeFlags_kTime_Prec_Default_bit_index
+}
+
+type NArchive_NUpdate_NOperationResult = int32
+
+const (
+	NArchive_NUpdate_NOperationResult_kOK NArchive_NUpdate_NOperationResult = iota
+	NArchive_NUpdate_NOperationResult_kError
+)
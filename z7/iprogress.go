@@ -0,0 +1,9 @@
+//go:build windows
+
+package z7
+
+// CPP/7zip/IProgress.h
+
+var (
+	IID_IProgress = Z7_DECL_IFACE_7ZIP___IID(0, 0x05)
+)
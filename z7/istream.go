@@ -0,0 +1,19 @@
+//go:build windows
+
+package z7
+
+import "github.com/lxn/win"
+
+// CPP/7zip/IStream.h
+
+func Z7_IFACE_CONSTR_STREAM___IID(n byte) win.IID {
+	return Z7_DECL_IFACE_7ZIP___IID(3, n)
+}
+
+var (
+	IID_ISequentialInStream  = Z7_IFACE_CONSTR_STREAM___IID(0x01)
+	IID_ISequentialOutStream = Z7_IFACE_CONSTR_STREAM___IID(0x02)
+	IID_IInStream            = Z7_IFACE_CONSTR_STREAM___IID(0x03)
+	IID_IOutStream           = Z7_IFACE_CONSTR_STREAM___IID(0x04)
+	IID_IStreamGetSize       = Z7_IFACE_CONSTR_STREAM___IID(0x06)
+)
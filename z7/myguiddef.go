@@ -0,0 +1,52 @@
+package z7
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// CPP/Common/MyGuidDef.h
+
+// GUID has the same layout as the Windows GUID, and can be converted to it.
+type GUID struct {
+	Data1 uint32
+	Data2 uint16
+	Data3 uint16
+	Data4 [8]byte
+}
+
+// ParseGUID parses a GUID in the registry format (e.g.,
+// "{3a128a09-88fe-45db-8727-565dff106ebe}").
+func ParseGUID(s string) (GUID, error) {
+	var g GUID
+	if len(s) != 38 || s[0] != '{' || s[9] != '-' || s[14] != '-' || s[19] != '-' || s[24] != '-' || s[37] != '}' {
+		return g, errors.New("invalid guid " + s)
+	}
+	b, err := hex.DecodeString(s[1:9] + s[10:14] + s[15:19] + s[20:24] + s[25:37])
+	if err != nil {
+		return g, errors.New("invalid guid " + s)
+	}
+	g.Data1 = uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	g.Data2 = uint16(b[4])<<8 | uint16(b[5])
+	g.Data3 = uint16(b[6])<<8 | uint16(b[7])
+	copy(g.Data4[:], b[8:])
+	return g, nil
+}
+
+// MustGUID is like ParseGUID, but panics on error.
+func MustGUID(s string) GUID {
+	g, err := ParseGUID(s)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// String formats g in the registry format.
+func (g GUID) String() string {
+	return fmt.Sprintf("{%08X-%04X-%04X-%02X%02X-%02X%02X%02X%02X%02X%02X}",
+		g.Data1, g.Data2, g.Data3,
+		g.Data4[0], g.Data4[1], g.Data4[2], g.Data4[3],
+		g.Data4[4], g.Data4[5], g.Data4[6], g.Data4[7])
+}
@@ -0,0 +1,14 @@
+package z7
+
+// CPP/Common/MyWindows.h
+
+const (
+	FILE_ATTRIBUTE_READONLY  uint32 = 0x0001
+	FILE_ATTRIBUTE_HIDDEN    uint32 = 0x0002
+	FILE_ATTRIBUTE_SYSTEM    uint32 = 0x0004
+	FILE_ATTRIBUTE_DIRECTORY uint32 = 0x0010
+	FILE_ATTRIBUTE_ARCHIVE   uint32 = 0x0020
+	FILE_ATTRIBUTE_NORMAL    uint32 = 0x0080
+
+	FILE_ATTRIBUTE_UNIX_EXTENSION uint32 = 0x8000 // the high 16 bits contain the unix st_mode
+)
@@ -0,0 +1,126 @@
+package z7
+
+// CPP/7zip/PropID.h
+
+type PROPID = uint32
+
+const (
+	PROPID_kpidNoProperty PROPID = iota
+	PROPID_kpidMainSubfile
+	PROPID_kpidHandlerItemIndex
+	PROPID_kpidPath
+	PROPID_kpidName
+	PROPID_kpidExtension
+	PROPID_kpidIsDir
+	PROPID_kpidSize
+	PROPID_kpidPackSize
+	PROPID_kpidAttrib
+	PROPID_kpidCTime
+	PROPID_kpidATime
+	PROPID_kpidMTime
+	PROPID_kpidSolid
+	PROPID_kpidCommented
+	PROPID_kpidEncrypted
+	PROPID_kpidSplitBefore
+	PROPID_kpidSplitAfter
+	PROPID_kpidDictionarySize
+	PROPID_kpidCRC
+	PROPID_kpidType
+	PROPID_kpidIsAnti
+	PROPID_kpidMethod
+	PROPID_kpidHostOS
+	PROPID_kpidFileSystem
+	PROPID_kpidUser
+	PROPID_kpidGroup
+	PROPID_kpidBlock
+	PROPID_kpidComment
+	PROPID_kpidPosition
+	PROPID_kpidPrefix
+	PROPID_kpidNumSubDirs
+	PROPID_kpidNumSubFiles
+	PROPID_kpidUnpackVer
+	PROPID_kpidVolume
+	PROPID_kpidIsVolume
+	PROPID_kpidOffset
+	PROPID_kpidLinks
+	PROPID_kpidNumBlocks
+	PROPID_kpidNumVolumes
+	PROPID_kpidTimeType
+	PROPID_kpidBit64
+	PROPID_kpidBigEndian
+	PROPID_kpidCpu
+	PROPID_kpidPhySize
+	PROPID_kpidHeadersSize
+	PROPID_kpidChecksum
+	PROPID_kpidCharacts
+	PROPID_kpidVa
+	PROPID_kpidId
+	PROPID_kpidShortName
+	PROPID_kpidCreatorApp
+	PROPID_kpidSectorSize
+	PROPID_kpidPosixAttrib
+	PROPID_kpidSymLink
+	PROPID_kpidError
+	PROPID_kpidTotalSize
+	PROPID_kpidFreeSpace
+	PROPID_kpidClusterSize
+	PROPID_kpidVolumeName
+	PROPID_kpidLocalName
+	PROPID_kpidProvider
+	PROPID_kpidNtSecure
+	PROPID_kpidIsAltStream
+	PROPID_kpidIsAux
+	PROPID_kpidIsDeleted
+	PROPID_kpidIsTree
+	PROPID_kpidSha1
+	PROPID_kpidSha256
+	PROPID_kpidErrorType
+	PROPID_kpidNumErrors
+	PROPID_kpidErrorFlags
+	PROPID_kpidWarningFlags
+	PROPID_kpidWarning
+	PROPID_kpidNumStreams
+	PROPID_kpidNumAltStreams
+	PROPID_kpidAltStreamsSize
+	PROPID_kpidVirtualSize
+	PROPID_kpidUnpackSize
+	PROPID_kpidTotalPhySize
+	PROPID_kpidVolumeIndex
+	PROPID_kpidSubType
+	PROPID_kpidShortComment
+	PROPID_kpidCodePage
+	PROPID_kpidIsNotArcType
+	PROPID_kpidPhySizeCantBeDetected
+	PROPID_kpidZerosTailIsAllowed
+	PROPID_kpidTailSize
+	PROPID_kpidEmbeddedStubSize
+	PROPID_kpidNtReparse
+	PROPID_kpidHardLink
+	PROPID_kpidINode
+	PROPID_kpidStreamId
+	PROPID_kpidReadOnly
+	PROPID_kpidOutName
+	PROPID_kpidCopyLink
+	PROPID_kpidArcFileName
+	PROPID_kpidIsHash
+	PROPID_kpidChangeTime
+	PROPID_kpidUserId
+	PROPID_kpidGroupId
+	PROPID_kpidDeviceMajor
+	PROPID_kpidDeviceMinor
+	PROPID_kpidDevMajor
+	PROPID_kpidDevMinor
+	PROPID_kpid_NUM_DEFINED
+
+	PROPID_kpidUserDefined PROPID = 0x10000
+)
+
+const (
+	K_PropVar_TimePrec_0        = 0 // unknown precision (old 7-Zip)
+	K_PropVar_TimePrec_Unix     = 1 // 1 second
+	K_PropVar_TimePrec_DOS      = 2 // 2 seconds
+	K_PropVar_TimePrec_HighPrec = 3 // unknown, but greater than 100ns
+	K_PropVar_TimePrec_Base     = 16
+	K_PropVar_TimePrec_100ns    = K_PropVar_TimePrec_Base + 7
+	K_PropVar_TimePrec_1ns      = K_PropVar_TimePrec_Base + 9
+)
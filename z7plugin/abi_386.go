@@ -0,0 +1,26 @@
+//go:build windows && 386
+
+package z7plugin
+
+import (
+	"syscall"
+
+	"github.com/pg9182/7zplugin/winext"
+)
+
+// NOTE: 64-bit arguments are passed as two 32-bit words on the stack
+
+// callUint64 is like call, but with a 64-bit first argument.
+//
+//go:uintptrescapes
+func (p comPtr) callUint64(method int, a uint64, arg ...uintptr) winext.HRESULT {
+	return p.call(method, append([]uintptr{uintptr(uint32(a)), uintptr(a >> 32)}, arg...)...)
+}
+
+// newSeekCallback is like comMethod for a Seek-like method.
+func newSeekCallback(name string, fn func(this uintptr, offset int64, seekOrigin uint32, newPosition *uint64) uintptr) uintptr {
+	fn = traceFunc("com", name, nil, fn)
+	return syscall.NewCallback(func(this uintptr, offsetLo, offsetHi uint32, seekOrigin uint32, newPosition *uint64) uintptr {
+		return fn(this, int64(uint64(offsetHi)<<32|uint64(offsetLo)), seekOrigin, newPosition)
+	})
+}
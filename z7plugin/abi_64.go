@@ -0,0 +1,19 @@
+//go:build windows && (amd64 || arm64)
+
+package z7plugin
+
+import (
+	"github.com/pg9182/7zplugin/winext"
+)
+
+// callUint64 is like call, but with a 64-bit first argument.
+//
+//go:uintptrescapes
+func (p comPtr) callUint64(method int, a uint64, arg ...uintptr) winext.HRESULT {
+	return p.call(method, append([]uintptr{uintptr(a)}, arg...)...)
+}
+
+// newSeekCallback is like comMethod for a Seek-like method.
+func newSeekCallback(name string, fn func(this uintptr, offset int64, seekOrigin uint32, newPosition *uint64) uintptr) uintptr {
+	return comMethod(name, fn)
+}
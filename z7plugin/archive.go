@@ -3,6 +3,8 @@
 package z7plugin
 
 import (
+	"sync"
+
 	"github.com/lxn/win"
 	"github.com/pg9182/7zplugin/winext"
 	"github.com/pg9182/7zplugin/z7"
@@ -13,38 +15,22 @@ import (
 // CPP/7zip/Archive/ArchiveExports.cpp
 
 func init() {
-	internal.Archive2.CreateObject = _CreateObject
-	internal.Archive2.GetHandlerProperty = _GetHandlerProperty
-	internal.Archive2.GetNumberOfFormats = _GetNumberOfFormats
-	internal.Archive2.GetHandlerProperty2 = _GetHandlerProperty2
-	internal.Archive2.GetIsArc = _GetIsArc
-}
-
-type CArcInfo struct {
-	Flags            z7.NArchive_NArcInfoFlags
-	CLSID            win.CLSID
-	SignatureOffset  uint16
-	Signature        string
-	Name             string
-	Ext              string
-	AddExt           string
-	TimeFlags        uint32
-	CreateInArchive  func() // TODO: accept interface, internally allocate cgo mem like { *InArchiveVtbl { syscall.NewCallback(inArchiveVtblThunkXXX)... } cgo.Handle(iface) }
-	CreateOutArchive func() // TODO: accept interface, internally allocate cgo mem like { *OutArchiveVtbl { syscall.NewCallback(inArchiveVtblThunkXXX)... } cgo.Handle(iface) }
-	IsArc            func(b []byte) z7.NArchive_k_IsArc_Res
-}
-
-func (arcInfo CArcInfo) IsMultiSignature() bool {
-	return arcInfo.Flags&z7.NArchive_NArcInfoFlags_kMultiSignature != 0
+	export("CreateObject", &internal.Archive2.CreateObject, _CreateObject)
+	export("GetHandlerProperty", &internal.Archive2.GetHandlerProperty, _GetHandlerProperty)
+	export("GetNumberOfFormats", &internal.Archive2.GetNumberOfFormats, _GetNumberOfFormats)
+	export("GetHandlerProperty2", &internal.Archive2.GetHandlerProperty2, _GetHandlerProperty2)
+	export("GetIsArc", &internal.Archive2.GetIsArc, _GetIsArc)
 }
 
-var _Arcs []*CArcInfo
-var isArcCache []internal.Func_IsArc
-
-func RegisterArc(arcInfo *CArcInfo) {
-	_Arcs = append(_Arcs, arcInfo)
-	isArcCache = append(isArcCache, internal.Func_IsArc_Wrap(arcInfo.IsArc))
-}
+// isArcCache contains the IsArc callbacks for _Arcs. It is created when first
+// used since plugins register formats after our init.
+var isArcCache = sync.OnceValue(func() []internal.Func_IsArc {
+	c := make([]internal.Func_IsArc, len(_Arcs))
+	for i, arc := range _Arcs {
+		c[i] = internal.Func_IsArc_Wrap(arc.IsArc)
+	}
+	return c
+})
 
 func _CreateArchiver(clsid win.CLSID, iid win.IID, outObject *uintptr) uint32 {
 	var (
@@ -54,18 +40,15 @@ func _CreateArchiver(clsid win.CLSID, iid win.IID, outObject *uintptr) uint32 {
 	if !needIn && !needOut {
 		return win.E_NOINTERFACE
 	}
+	loadModuleSettings()
 	for _, arc := range _Arcs {
-		if arc.CLSID == clsid {
+		if win.CLSID(arc.CLSID) == clsid {
 			if needIn && arc.CreateInArchive != nil {
-				win.MessageBox(0, win.SysAllocString(winext.GUIDToString(clsid)), win.SysAllocString("TODO"), win.MB_OK) // TODO: actually create it
-				// *outObject = ...
-				// AddRef()
+				*outObject = newInArchive(arc).ref()
 				return win.S_OK
 			}
-			if needOut {
-				win.MessageBox(0, win.SysAllocString(winext.GUIDToString(clsid)), win.SysAllocString("TODO"), win.MB_OK) // TODO: actually create it
-				// *outObject = ...
-				// AddRef()
+			if needOut && arc.CreateOutArchive != nil {
+				*outObject = newOutArchive(arc).ref()
 				return win.S_OK
 			}
 		}
@@ -91,7 +74,7 @@ func _GetHandlerProperty2(formatIndex uint32, propID uint32, value *win.VARIANT)
 	case z7.NArchive_NHandlerPropID_kName:
 		value.SetBSTR(win.SysAllocString(arc.Name))
 	case z7.NArchive_NHandlerPropID_kClassID:
-		value.SetBSTR(winext.SysAllocStringByteLenGUID(arc.CLSID))
+		value.SetBSTR(winext.SysAllocStringByteLenGUID(win.CLSID(arc.CLSID)))
 	case z7.NArchive_NHandlerPropID_kExtension:
 		value.SetBSTR(win.SysAllocString(arc.Ext))
 	case z7.NArchive_NHandlerPropID_kAddExtension:
@@ -123,7 +106,7 @@ func _GetHandlerProperty2(formatIndex uint32, propID uint32, value *win.VARIANT)
 	case z7.NArchive_NHandlerPropID_kFlags:
 		value.SetULong(uint32(arc.Flags))
 	case z7.NArchive_NHandlerPropID_kTimeFlags:
-		value.SetULong(uint32(arc.TimeFlags))
+		value.SetULong(uint32(arc.TimeFlags) | timeFlags(arc.TimePrecs))
 	case z7.NArchive_NHandlerPropID_kSignatureOffset:
 		value.SetULong(uint32(arc.SignatureOffset))
 	case z7.NArchive_NHandlerPropID_kSignature:
@@ -143,6 +126,6 @@ func _GetIsArc(formatIndex uint32, isArc *internal.Func_IsArc) winext.HRESULT {
 	if int(formatIndex) >= len(_Arcs) {
 		return win.E_INVALIDARG
 	}
-	*isArc = internal.Func_IsArc(isArcCache[formatIndex]) // note: can be null
+	*isArc = isArcCache()[formatIndex] // note: can be null
 	return win.S_OK
 }
@@ -0,0 +1,474 @@
+package z7plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArchiveFS is a read-only fs.FS containing the items of an InArchive. This
+// allows handlers to be used as a regular Go library outside of 7-Zip.
+//
+// Directories which aren't items in the archive are created as needed, and
+// items with invalid paths or which are alternate streams are ignored. Files
+// implement io.ReaderAt and io.Seeker.
+//
+// Files opened with Open are extracted into memory with InArchive.Extract when
+// they are first read, so the handler checks their integrity (e.g., the CRC),
+// and reading fails if it doesn't match. Files opened with OpenStream are read
+// directly from the archive if the InArchive implements InArchiveGetStream,
+// which is faster for large files, but skips those checks.
+type ArchiveFS struct {
+	mu   sync.Mutex // for the InArchive
+	a    InArchive
+	c    []io.Closer
+	root *archiveNode
+}
+
+var (
+	_ fs.ReadDirFS = (*ArchiveFS)(nil)
+	_ fs.StatFS    = (*ArchiveFS)(nil)
+)
+
+type archiveNode struct {
+	index    int // -1 if not an item
+	item     Item
+	children []*archiveNode // sorted by name
+}
+
+// NewArchiveFS opens an archive from r using a. If vol is not nil and a
+// implements InArchiveOpenVolumes, it is used to open the archive. If it
+// succeeds, r and vol are closed when the ArchiveFS is closed if they implement
+// io.Closer.
+func NewArchiveFS(ctx context.Context, a InArchive, r io.ReaderAt, size int64, vol Volumes) (*ArchiveFS, error) {
+	var err error
+	if av, ok := a.(InArchiveOpenVolumes); ok && vol != nil {
+		err = av.OpenVolumes(ctx, r, size, vol, nopProgress{})
+	} else {
+		err = a.Open(ctx, r, size, nopProgress{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	afs := &ArchiveFS{a: a, root: &archiveNode{index: -1, item: Item{IsDir: true}}}
+	for _, x := range []any{vol, r} {
+		if c, ok := x.(io.Closer); ok {
+			afs.c = append(afs.c, c)
+		}
+	}
+	for i := range a.NumItems() {
+		it, err := a.Item(i)
+		if err != nil {
+			afs.Close()
+			return nil, err
+		}
+		afs.add(i, it)
+	}
+	return afs, nil
+}
+
+// OpenArchiveFS opens the archive file name using the first registered format
+// which can open it. Formats are tried if the signature matches, or if there
+// isn't a signature and the extension matches. Other files next to it are
+// available to formats implementing InArchiveOpenVolumes.
+func OpenArchiveFS(ctx context.Context, name string) (*ArchiveFS, *CArcInfo, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	for _, arc := range matchArcs(f, name) {
+		vol := DirVolumes(name)
+		afs, err := NewArchiveFS(ctx, arc.CreateInArchive(), f, fi.Size(), vol)
+		if err != nil {
+			vol.(io.Closer).Close()
+			if errors.Is(err, ErrNotArchive) {
+				continue
+			}
+			f.Close()
+			return nil, arc, err
+		}
+		return afs, arc, nil
+	}
+	f.Close()
+	return nil, nil, &fs.PathError{Op: "open", Path: name, Err: ErrNotArchive}
+}
+
+// matchArcs gets the registered formats which may be able to open r.
+func matchArcs(r io.ReaderAt, name string) []*CArcInfo {
+	var arcs []*CArcInfo
+	for _, arc := range _Arcs {
+		if arc.CreateInArchive == nil {
+			continue
+		}
+		if arc.Signature != "" {
+			var sigs []string
+			if sig := arc.Signature; arc.IsMultiSignature() {
+				for len(sig) != 0 {
+					n := min(int(sig[0]), len(sig)-1)
+					sigs, sig = append(sigs, sig[1:1+n]), sig[1+n:]
+				}
+			} else {
+				sigs = append(sigs, sig)
+			}
+			for _, sig := range sigs {
+				b := make([]byte, len(sig))
+				if _, err := r.ReadAt(b, int64(arc.SignatureOffset)); err == nil && string(b) == sig {
+					arcs = append(arcs, arc)
+					break
+				}
+			}
+		} else {
+			ext := strings.TrimPrefix(filepath.Ext(name), ".")
+			for _, x := range strings.Fields(arc.Ext) {
+				if strings.EqualFold(x, ext) {
+					arcs = append(arcs, arc)
+					break
+				}
+			}
+		}
+	}
+	return arcs
+}
+
+// add adds an item to the tree.
+func (afs *ArchiveFS) add(index int, it Item) {
+	if it.IsAltStream {
+		return
+	}
+	name := path.Clean(strings.TrimPrefix(it.Path, "/"))
+	if name == "." || !fs.ValidPath(name) {
+		return
+	}
+	n := afs.root
+	for _, elem := range strings.Split(name, "/") {
+		i, ok := slices.BinarySearchFunc(n.children, elem, func(c *archiveNode, elem string) int {
+			return strings.Compare(path.Base(c.item.Path), elem)
+		})
+		if !ok {
+			c := &archiveNode{index: -1, item: Item{Path: path.Join(n.item.Path, elem), IsDir: true}}
+			n.children = slices.Insert(n.children, i, c)
+		}
+		n = n.children[i]
+		if !n.item.IsDir {
+			return // file with the same name as a parent dir
+		}
+	}
+	if n.index != -1 || len(n.children) != 0 && !it.IsDir {
+		return // duplicate
+	}
+	n.index, n.item = index, it
+	n.item.Path = name
+}
+
+// lookup gets the node for a path.
+func (afs *ArchiveFS) lookup(op, name string) (*archiveNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	n := afs.root
+	if name != "." {
+		for _, elem := range strings.Split(name, "/") {
+			i, ok := slices.BinarySearchFunc(n.children, elem, func(c *archiveNode, elem string) int {
+				return strings.Compare(path.Base(c.item.Path), elem)
+			})
+			if !ok {
+				return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+			}
+			n = n.children[i]
+		}
+	}
+	return n, nil
+}
+
+// Open opens a file, extracting it with InArchive.Extract when it is first
+// read.
+func (afs *ArchiveFS) Open(name string) (fs.File, error) {
+	return afs.open("open", name, false)
+}
+
+// OpenStream is like Open, but reads the file directly from the archive using
+// InArchiveGetStream if the InArchive implements it and supports it for the
+// item. The integrity of the contents is not checked.
+func (afs *ArchiveFS) OpenStream(name string) (fs.File, error) {
+	return afs.open("openstream", name, true)
+}
+
+func (afs *ArchiveFS) open(op, name string, stream bool) (fs.File, error) {
+	n, err := afs.lookup(op, name)
+	if err != nil {
+		return nil, err
+	}
+	if n.item.IsDir {
+		return &archiveDir{n: n}, nil
+	}
+	return &archiveFile{afs: afs, n: n, stream: stream}, nil
+}
+
+func (afs *ArchiveFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := afs.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !n.item.IsDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	es := make([]fs.DirEntry, len(n.children))
+	for i, c := range n.children {
+		es[i] = archiveInfo{c}
+	}
+	return es, nil
+}
+
+func (afs *ArchiveFS) Stat(name string) (fs.FileInfo, error) {
+	n, err := afs.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return archiveInfo{n}, nil
+}
+
+// Extract writes the contents of a file to w using InArchive.Extract, which
+// checks its integrity.
+func (afs *ArchiveFS) Extract(ctx context.Context, name string, w io.Writer) error {
+	n, err := afs.lookup("extract", name)
+	if err != nil {
+		return err
+	}
+	if n.item.IsDir {
+		return &fs.PathError{Op: "extract", Path: name, Err: errors.New("is a directory")}
+	}
+	afs.mu.Lock()
+	defer afs.mu.Unlock()
+
+	if err := afs.a.Extract(ctx, n.index, w); err != nil {
+		return &fs.PathError{Op: "extract", Path: name, Err: err}
+	}
+	return nil
+}
+
+// Index gets the index of the item at a path in the InArchive, or -1 if it is
+// a directory which isn't an item.
+func (afs *ArchiveFS) Index(name string) (int, error) {
+	n, err := afs.lookup("index", name)
+	if err != nil {
+		return -1, err
+	}
+	return n.index, nil
+}
+
+// Close closes the InArchive and the files opened for it.
+func (afs *ArchiveFS) Close() error {
+	afs.mu.Lock()
+	defer afs.mu.Unlock()
+
+	err := afs.a.Close()
+	for _, c := range afs.c {
+		c.Close()
+	}
+	afs.c = nil
+	return err
+}
+
+// archiveInfo implements fs.FileInfo and fs.DirEntry for a node. Sys returns
+// the Item.
+type archiveInfo struct {
+	n *archiveNode
+}
+
+func (fi archiveInfo) Name() string {
+	if fi.n.item.Path == "" {
+		return "."
+	}
+	return path.Base(fi.n.item.Path)
+}
+
+func (fi archiveInfo) Size() int64 {
+	if fi.n.item.IsDir {
+		return 0
+	}
+	return int64(fi.n.item.Size)
+}
+
+func (fi archiveInfo) Mode() fs.FileMode {
+	m := fi.n.item.Mode
+	if m == 0 {
+		m = 0444
+		if fi.n.item.IsDir {
+			m = 0555
+		}
+	}
+	if fi.n.item.IsDir {
+		m = m&^fs.ModeType | fs.ModeDir
+	}
+	return m
+}
+
+func (fi archiveInfo) ModTime() time.Time         { return fi.n.item.MTime }
+func (fi archiveInfo) IsDir() bool                { return fi.n.item.IsDir }
+func (fi archiveInfo) Sys() any                   { return fi.n.item }
+func (fi archiveInfo) Type() fs.FileMode          { return fi.Mode().Type() }
+func (fi archiveInfo) Info() (fs.FileInfo, error) { return fi, nil }
+func (fi archiveInfo) String() string             { return fs.FormatFileInfo(fi) }
+
+// archiveDir is an open directory.
+type archiveDir struct {
+	n   *archiveNode
+	off int
+}
+
+func (d *archiveDir) Stat() (fs.FileInfo, error) {
+	return archiveInfo{d.n}, nil
+}
+
+func (d *archiveDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.n.item.Path, Err: errors.New("is a directory")}
+}
+
+func (d *archiveDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	n := len(d.n.children) - d.off
+	if n == 0 && count > 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && n > count {
+		n = count
+	}
+	es := make([]fs.DirEntry, n)
+	for i := range es {
+		es[i] = archiveInfo{d.n.children[d.off+i]}
+	}
+	d.off += n
+	return es, nil
+}
+
+func (d *archiveDir) Close() error {
+	return nil
+}
+
+// archiveFile is an open file.
+type archiveFile struct {
+	afs    *ArchiveFS
+	n      *archiveNode
+	stream bool // use InArchiveGetStream if possible
+	r      *io.SectionReader
+}
+
+var (
+	_ io.ReaderAt = (*archiveFile)(nil)
+	_ io.Seeker   = (*archiveFile)(nil)
+)
+
+// open gets the contents of the file.
+func (f *archiveFile) open() error {
+	if f.r != nil {
+		return nil
+	}
+	f.afs.mu.Lock()
+	defer f.afs.mu.Unlock()
+
+	if gs, ok := f.afs.a.(InArchiveGetStream); ok && f.stream {
+		r, size, err := gs.GetStream(f.n.index)
+		if err != nil {
+			return &fs.PathError{Op: "read", Path: f.n.item.Path, Err: err}
+		}
+		if r != nil {
+			f.r = io.NewSectionReader(r, 0, size)
+			return nil
+		}
+	}
+	var buf bytes.Buffer
+	if err := f.afs.a.Extract(context.Background(), f.n.index, &buf); err != nil {
+		return &fs.PathError{Op: "read", Path: f.n.item.Path, Err: err}
+	}
+	f.r = io.NewSectionReader(bytes.NewReader(buf.Bytes()), 0, int64(buf.Len()))
+	return nil
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) {
+	return archiveInfo{f.n}, nil
+}
+
+func (f *archiveFile) Read(b []byte) (int, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	return f.r.Read(b)
+}
+
+func (f *archiveFile) ReadAt(b []byte, off int64) (int, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	return f.r.ReadAt(b, off)
+}
+
+func (f *archiveFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	return f.r.Seek(offset, whence)
+}
+
+func (f *archiveFile) Close() error {
+	return nil
+}
+
+// DirVolumes returns a Volumes for the archive file name, which opens other
+// files in the same directory. It implements io.Closer to close the opened
+// files.
+func DirVolumes(name string) Volumes {
+	return &dirVolumes{name: name}
+}
+
+type dirVolumes struct {
+	name  string
+	files []*os.File
+}
+
+func (v *dirVolumes) Name() string {
+	return filepath.Base(v.name)
+}
+
+func (v *dirVolumes) OpenVolume(name string) (io.ReaderAt, int64, error) {
+	if name != filepath.Base(name) {
+		return nil, 0, fs.ErrNotExist
+	}
+	f, err := os.Open(filepath.Join(filepath.Dir(v.name), name))
+	if err != nil {
+		return nil, 0, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	v.files = append(v.files, f)
+	return f, fi.Size(), nil
+}
+
+func (v *dirVolumes) Close() error {
+	for _, f := range v.files {
+		f.Close()
+	}
+	v.files = nil
+	return nil
+}
+
+// nopProgress ignores progress.
+type nopProgress struct{}
+
+func (nopProgress) SetTotal(files, bytes uint64) error     { return nil }
+func (nopProgress) SetCompleted(files, bytes uint64) error { return nil }
@@ -0,0 +1,100 @@
+package z7plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pg9182/7zplugin/z7"
+)
+
+// testArchive is an InArchive with items stored uncompressed, which checks the
+// CRC when extracting, but not for GetStream.
+type testArchive struct {
+	items []testArchiveItem
+}
+
+type testArchiveItem struct {
+	path string
+	data string
+	crc  uint32
+}
+
+func (a *testArchive) Open(ctx context.Context, r io.ReaderAt, size int64, p Progress) error {
+	return nil
+}
+
+func (a *testArchive) Close() error {
+	return nil
+}
+
+func (a *testArchive) NumItems() int {
+	return len(a.items)
+}
+
+func (a *testArchive) Item(index int) (Item, error) {
+	return Item{Path: a.items[index].path, Size: uint64(len(a.items[index].data))}, nil
+}
+
+func (a *testArchive) Extract(ctx context.Context, index int, w io.Writer) error {
+	it := a.items[index]
+	if _, err := io.WriteString(w, it.data); err != nil {
+		return err
+	}
+	if crc32.ChecksumIEEE([]byte(it.data)) != it.crc {
+		return ExtractError(z7.NArchive_NExtract_NOperationResult_kCRCError)
+	}
+	return nil
+}
+
+func (a *testArchive) GetStream(index int) (io.ReaderAt, int64, error) {
+	it := a.items[index]
+	return bytes.NewReader([]byte(it.data)), int64(len(it.data)), nil
+}
+
+func TestArchiveFS(t *testing.T) {
+	a := &testArchive{items: []testArchiveItem{
+		{path: "dir/ok.txt", data: "hello, world", crc: crc32.ChecksumIEEE([]byte("hello, world"))},
+		{path: "bad.txt", data: "HELLO, world", crc: crc32.ChecksumIEEE([]byte("hello, world"))},
+	}}
+	afs, err := NewArchiveFS(context.Background(), a, bytes.NewReader(nil), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer afs.Close()
+
+	if sub, err := fs.Sub(afs, "dir"); err != nil {
+		t.Errorf("sub: %v", err)
+	} else if err := fstest.TestFS(sub, "ok.txt"); err != nil {
+		t.Errorf("fstest: %v", err)
+	}
+
+	if buf, err := fs.ReadFile(afs, "dir/ok.txt"); err != nil || string(buf) != "hello, world" {
+		t.Errorf("read ok.txt: %q, %v", buf, err)
+	}
+
+	// Open extracts, so the CRC is checked
+	f, err := afs.Open("bad.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(f); !errors.Is(err, ExtractError(z7.NArchive_NExtract_NOperationResult_kCRCError)) {
+		t.Errorf("expected crc error when reading bad.txt, got %v", err)
+	}
+	f.Close()
+
+	// OpenStream uses GetStream, which doesn't
+	f, err = afs.OpenStream("bad.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf, err := io.ReadAll(f); err != nil || string(buf) != "HELLO, world" {
+		t.Errorf("read bad.txt stream: %q, %v", buf, err)
+	}
+	f.Close()
+}
@@ -13,12 +13,12 @@ import (
 // CPP/7zip/Compress/CodecExports.cpp
 
 func init() {
-	internal.Archive2.GetNumberOfMethods = _GetNumberOfMethods
-	internal.Archive2.GetMethodProperty = _GetMethodProperty
-	internal.Archive2.CreateDecoder = _CreateDecoder
-	internal.Archive2.CreateEncoder = _CreateEncoder
-	internal.Archive2.GetHashers = _GetHashers
-	internal.Archive2.GetModuleProp = _GetModuleProp
+	export("GetNumberOfMethods", &internal.Archive2.GetNumberOfMethods, _GetNumberOfMethods)
+	export("GetMethodProperty", &internal.Archive2.GetMethodProperty, _GetMethodProperty)
+	export("CreateDecoder", &internal.Archive2.CreateDecoder, _CreateDecoder)
+	export("CreateEncoder", &internal.Archive2.CreateEncoder, _CreateEncoder)
+	export("GetHashers", &internal.Archive2.GetHashers, _GetHashers)
+	export("GetModuleProp", &internal.Archive2.GetModuleProp, _GetModuleProp)
 }
 
 // TODO: these are stubs
@@ -0,0 +1,197 @@
+//go:build windows
+
+package z7plugin
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/lxn/win"
+	"github.com/pg9182/7zplugin/winext"
+	"golang.org/x/sys/windows"
+)
+
+// CPP/Common/MyCom.h
+// NOTE: COM methods use the stdcall calling convention, and 7-Zip on Windows
+// doesn't have a virtual destructor in IUnknown
+
+const ptrSize = unsafe.Sizeof(uintptr(0))
+
+// comVtbl is a COM interface vtable implemented by Go callbacks. The first
+// three methods are always the ones from IUnknown.
+type comVtbl struct {
+	iid []win.IID // the interfaces (other than IUnknown) the vtable implements
+	ptr uintptr   // unmanaged array of method pointers
+}
+
+// newComVtbl allocates a vtable for the provided interfaces. The methods
+// should be created with comMethod, and must not include the IUnknown ones.
+func newComVtbl(iid []win.IID, method ...uintptr) *comVtbl {
+	method = append([]uintptr{comQueryInterface, comAddRef, comRelease}, method...)
+	ptr, err := windows.LocalAlloc(windows.LMEM_FIXED, uint32(uintptr(len(method))*ptrSize))
+	if err != nil {
+		panic(fmt.Errorf("allocate vtable: %w", err))
+	}
+	copy(unsafe.Slice((*uintptr)(unsafe.Pointer(ptr)), len(method)), method)
+	return &comVtbl{iid, ptr}
+}
+
+// comObject is a reference-counted COM object implemented by a Go value.
+type comObject struct {
+	refs atomic.Int32
+	mem  uintptr    // unmanaged array of vtable pointers, one per interface
+	vtbl []*comVtbl // the interfaces implemented by the object
+	impl any
+}
+
+// comReleaser may be implemented by comObject implementations to be notified
+// when the last reference is released.
+type comReleaser interface {
+	comRelease()
+}
+
+// comObjects maps interface pointers to the object they belong to.
+var comObjects sync.Map // map[uintptr]*comObject
+
+// newComObject allocates a COM object for impl implementing the provided
+// interfaces. The returned object has no references.
+func newComObject(impl any, vtbl ...*comVtbl) *comObject {
+	mem, err := windows.LocalAlloc(windows.LMEM_FIXED, uint32(uintptr(len(vtbl))*ptrSize))
+	if err != nil {
+		panic(fmt.Errorf("allocate object: %w", err))
+	}
+	o := &comObject{mem: mem, vtbl: vtbl, impl: impl}
+	for i, v := range vtbl {
+		*(*uintptr)(unsafe.Pointer(o.iface(i))) = v.ptr
+		comObjects.Store(o.iface(i), o)
+	}
+	return o
+}
+
+// iface gets the pointer to the i'th interface of the object.
+func (o *comObject) iface(i int) uintptr {
+	return o.mem + uintptr(i)*ptrSize
+}
+
+// ref adds a reference to the object, returning the pointer to the primary
+// interface.
+func (o *comObject) ref() uintptr {
+	o.refs.Add(1)
+	return o.iface(0)
+}
+
+// comImpl gets the Go value implementing the COM object for the interface
+// pointer this.
+func comImpl[T any](this uintptr) T {
+	o, ok := comObjects.Load(this)
+	if !ok {
+		panic(fmt.Errorf("unknown com object %#x", this))
+	}
+	return o.(*comObject).impl.(T)
+}
+
+var (
+	iidIUnknown = win.IID_IUnknown
+
+	comQueryInterface = comMethod("IUnknown.QueryInterface", func(this uintptr, iid *win.IID, outObject *uintptr) uintptr {
+		v, _ := comObjects.Load(this)
+		o := v.(*comObject)
+		if *iid == iidIUnknown {
+			*outObject = o.ref()
+			return win.S_OK
+		}
+		for i, v := range o.vtbl {
+			for _, x := range v.iid {
+				if *iid == x {
+					o.refs.Add(1)
+					*outObject = o.iface(i)
+					return win.S_OK
+				}
+			}
+		}
+		*outObject = 0
+		return win.E_NOINTERFACE
+	})
+	comAddRef = comMethod("IUnknown.AddRef", func(this uintptr) uintptr {
+		v, _ := comObjects.Load(this)
+		return uintptr(v.(*comObject).refs.Add(1))
+	})
+	comRelease = comMethod("IUnknown.Release", func(this uintptr) uintptr {
+		v, _ := comObjects.Load(this)
+		return uintptr(v.(*comObject).release())
+	})
+)
+
+// release removes a reference from the object, freeing it if it was the last
+// one, and returning the number of remaining references.
+func (o *comObject) release() int32 {
+	n := o.refs.Add(-1)
+	if n == 0 {
+		for i := range o.vtbl {
+			comObjects.Delete(o.iface(i))
+		}
+		if _, err := windows.LocalFree(windows.Handle(o.mem)); err != nil {
+			panic(fmt.Errorf("free object: %w", err))
+		}
+		if r, ok := o.impl.(comReleaser); ok {
+			r.comRelease()
+		}
+	}
+	return n
+}
+
+// comPtr is a pointer to a COM interface implemented by the host.
+type comPtr uintptr
+
+// call calls the method at the specified vtable index.
+//
+//go:uintptrescapes
+func (p comPtr) call(method int, arg ...uintptr) winext.HRESULT {
+	var start time.Time
+	if tracer() != nil {
+		start = time.Now()
+	}
+	vtbl := *(*uintptr)(unsafe.Pointer(p))
+	fn := *(*uintptr)(unsafe.Pointer(vtbl + uintptr(method)*ptrSize))
+	r, _, _ := syscall.SyscallN(fn, append([]uintptr{uintptr(p)}, arg...)...)
+	if tracer() != nil {
+		traceHost(p, method, start, winext.HRESULT(r))
+	}
+	return winext.HRESULT(r)
+}
+
+// QueryInterface gets another interface from the object, returning zero if it
+// isn't implemented.
+func (p comPtr) QueryInterface(iid win.IID) comPtr {
+	var out uintptr
+	if p.call(0, uintptr(unsafe.Pointer(&iid)), uintptr(unsafe.Pointer(&out))) != win.S_OK {
+		return 0
+	}
+	return comPtr(out)
+}
+
+func (p comPtr) AddRef() {
+	p.call(1)
+}
+
+func (p comPtr) Release() {
+	p.call(2)
+}
+
+// hostError converts a HRESULT returned by the host into an error. If the user
+// cancelled the operation (E_ABORT), abort is called (if not nil).
+func hostError(hr winext.HRESULT, abort func()) error {
+	if hr == win.E_ABORT && abort != nil {
+		abort()
+	}
+	return HRESULT(hr)
+}
+
+// errorHRESULT converts an error returned by a handler into a HRESULT.
+func errorHRESULT(err error) winext.HRESULT {
+	return winext.HRESULT(hresultOf(err))
+}
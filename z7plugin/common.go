@@ -15,10 +15,10 @@ import (
 var CaseSensitive bool
 
 func init() {
-	internal.Archive2.CreateObject = _CreateObject
-	internal.Archive2.SetCodecs = _SetCodecs
-	internal.Archive2.SetLargePageMode = _SetLargePageMode
-	internal.Archive2.SetCaseSensitive = _SetCaseSensitive
+	export("CreateObject", &internal.Archive2.CreateObject, _CreateObject)
+	export("SetCodecs", &internal.Archive2.SetCodecs, _SetCodecs)
+	export("SetLargePageMode", &internal.Archive2.SetLargePageMode, _SetLargePageMode)
+	export("SetCaseSensitive", &internal.Archive2.SetCaseSensitive, _SetCaseSensitive)
 }
 
 func _CreateObject(clsid win.CLSID, iid win.IID, outObject *uintptr) uint32 {
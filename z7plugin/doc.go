@@ -4,4 +4,14 @@
 // 7-Zip. Put it in the `Formats“ directory in the install location. If the
 // library doesn't load for some reason, try clicking on About in 7zFM, which
 // will probably display an odd error message of some kind.
+//
+// To see what 7-Zip is calling, set Z7PLUGIN_TRACE to the path of a log file,
+// or create an empty file next to the DLL with the same name but a ".trace"
+// extension. Every export, COM method and host COM call will be logged to it
+// with the arguments, HRESULT and duration.
+//
+// Formats can have settings, which are loaded from a JSON file next to the DLL
+// with the same name but a ".settings.json" extension (see LoadSettings).
+//
+// Handlers can be fuzzed in-process with go test -fuzz using package z7fuzz.
 package z7plugin
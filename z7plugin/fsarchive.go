@@ -0,0 +1,137 @@
+package z7plugin
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/pg9182/7zplugin/z7"
+)
+
+// FSArchive creates an InArchive for a format implemented as a fs.FS. When the
+// archive is opened, open is called to create the fs.FS, which is walked (using
+// fs.ReadDirFS and fs.StatFS if implemented) to get the items, and files are
+// opened to extract them. If r doesn't contain an archive of this format, open
+// should return ErrNotArchive. If the fs.FS implements io.Closer, it is closed
+// when the archive is closed.
+//
+// The items have kpidPath, kpidSize, kpidMTime, kpidIsDir and kpidPosixAttrib
+// (and kpidAttrib) set from the fs.FileInfo.
+func FSArchive(open func(r io.ReaderAt, size int64) (fs.FS, error)) InArchive {
+	return &fsArchive{open: open}
+}
+
+type fsArchive struct {
+	open  func(r io.ReaderAt, size int64) (fs.FS, error)
+	fsys  fs.FS
+	items []Item
+}
+
+func (a *fsArchive) Open(ctx context.Context, r io.ReaderAt, size int64, p Progress) error {
+	fsys, err := a.open(r, size)
+	if err != nil {
+		return err
+	}
+	var items []Item
+	if err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if name == "." {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		it := Item{
+			Path:  name,
+			IsDir: fi.IsDir(),
+			MTime: fi.ModTime(),
+			Mode:  fi.Mode(),
+		}
+		if !it.IsDir && fi.Size() > 0 {
+			it.Size = uint64(fi.Size())
+		}
+		items = append(items, it)
+		return p.SetCompleted(uint64(len(items)), 0)
+	}); err != nil {
+		if c, ok := fsys.(io.Closer); ok {
+			c.Close()
+		}
+		return err
+	}
+	a.fsys, a.items = fsys, items
+	return nil
+}
+
+func (a *fsArchive) Close() error {
+	var err error
+	if c, ok := a.fsys.(io.Closer); ok {
+		err = c.Close()
+	}
+	a.fsys, a.items = nil, nil
+	return err
+}
+
+func (a *fsArchive) NumItems() int {
+	return len(a.items)
+}
+
+func (a *fsArchive) Item(index int) (Item, error) {
+	return a.items[index], nil
+}
+
+func (a *fsArchive) Extract(ctx context.Context, index int, w io.Writer) error {
+	it := a.items[index]
+	if it.IsDir {
+		return nil
+	}
+	f, err := a.fsys.Open(path.Clean(it.Path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, 32*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := f.Read(buf)
+		if n != 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (a *fsArchive) ItemProps() []z7.PROPID {
+	return []z7.PROPID{
+		z7.PROPID_kpidPath,
+		z7.PROPID_kpidIsDir,
+		z7.PROPID_kpidSize,
+		z7.PROPID_kpidMTime,
+		z7.PROPID_kpidAttrib,
+		z7.PROPID_kpidPosixAttrib,
+	}
+}
+
+func (a *fsArchive) ArcProps() []z7.PROPID {
+	return nil
+}
+
+func (a *fsArchive) ArcProperty(propID z7.PROPID) (any, error) {
+	return nil, nil
+}
@@ -0,0 +1,117 @@
+package z7plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/pg9182/7zplugin/z7"
+)
+
+func TestFSArchive(t *testing.T) {
+	var (
+		t1 = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		t2 = time.Date(2023, 6, 7, 8, 9, 10, 0, time.UTC)
+	)
+	fsys := fstest.MapFS{
+		"b.txt":     {Data: []byte("bee"), Mode: 0644, ModTime: t1},
+		"a/x.txt":   {Data: []byte("x"), Mode: 0444, ModTime: t2},
+		"a/run.sh":  {Data: []byte("#!/bin/sh\n"), Mode: 0755},
+		"c":         {Mode: fs.ModeDir | 0750, ModTime: t2},
+		"c/empty":   {Mode: 0600},
+		"a/b/c/d.x": {Data: make([]byte, 100000)},
+	}
+	a := FSArchive(func(r io.ReaderAt, size int64) (fs.FS, error) {
+		if size != 4 {
+			return nil, ErrNotArchive
+		}
+		return fsys, nil
+	})
+
+	if err := a.Open(context.Background(), bytes.NewReader(nil), 0, nopProgress{}); err != ErrNotArchive {
+		t.Fatalf("expected ErrNotArchive, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := a.Open(ctx, bytes.NewReader(nil), 4, nopProgress{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected open to be cancelled, got %v", err)
+	}
+
+	if err := a.Open(context.Background(), bytes.NewReader(nil), 4, nopProgress{}); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	items := []struct {
+		path  string
+		dir   bool
+		size  uint64
+		mtime time.Time
+		mode  fs.FileMode
+	}{
+		{"a", true, 0, time.Time{}, fs.ModeDir | 0555}, // created by MapFS
+		{"a/b", true, 0, time.Time{}, fs.ModeDir | 0555},
+		{"a/b/c", true, 0, time.Time{}, fs.ModeDir | 0555},
+		{"a/b/c/d.x", false, 100000, time.Time{}, 0},
+		{"a/run.sh", false, 10, time.Time{}, 0755},
+		{"a/x.txt", false, 1, t2, 0444},
+		{"b.txt", false, 3, t1, 0644},
+		{"c", true, 0, t2, fs.ModeDir | 0750},
+		{"c/empty", false, 0, time.Time{}, 0600},
+	}
+	if a.NumItems() != len(items) {
+		t.Fatalf("expected %d items, got %d", len(items), a.NumItems())
+	}
+	for i, want := range items {
+		it, err := a.Item(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if it.Path != want.path || it.IsDir != want.dir || it.Size != want.size || !it.MTime.Equal(want.mtime) || it.Mode != want.mode {
+			t.Errorf("item %d: got %q dir=%t size=%d mtime=%v mode=%v, expected %q dir=%t size=%d mtime=%v mode=%v",
+				i, it.Path, it.IsDir, it.Size, it.MTime, it.Mode, want.path, want.dir, want.size, want.mtime, want.mode)
+		}
+		if want.mode != 0 {
+			if x := it.Property(z7.PROPID_kpidPosixAttrib); x != unixMode(want.mode) {
+				t.Errorf("item %d: kpidPosixAttrib = %#o, expected %#o", i, x, unixMode(want.mode))
+			}
+			if x := it.Property(z7.PROPID_kpidAttrib); x != unixAttrib(want.mode) {
+				t.Errorf("item %d: kpidAttrib = %#x, expected %#x", i, x, unixAttrib(want.mode))
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	if err := a.Extract(context.Background(), 6, &b); err != nil || b.String() != "bee" {
+		t.Errorf("extract b.txt: %q, %v", b.String(), err)
+	}
+	b.Reset()
+	if err := a.Extract(context.Background(), 7, &b); err != nil || b.Len() != 0 {
+		t.Errorf("extract dir c: %q, %v", b.String(), err)
+	}
+	b.Reset()
+	if err := a.Extract(ctx, 3, &b); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected extract to be cancelled, got %v", err)
+	}
+
+	props := a.(InArchiveProps).ItemProps()
+	for _, id := range []z7.PROPID{z7.PROPID_kpidPath, z7.PROPID_kpidIsDir, z7.PROPID_kpidSize, z7.PROPID_kpidMTime, z7.PROPID_kpidAttrib, z7.PROPID_kpidPosixAttrib} {
+		if !slices.Contains(props, id) {
+			t.Errorf("ItemProps doesn't contain %d", id)
+		}
+	}
+
+	if err := a.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+	if a.NumItems() != 0 {
+		t.Errorf("expected no items after close")
+	}
+}
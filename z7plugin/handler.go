@@ -0,0 +1,367 @@
+package z7plugin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"iter"
+	"strconv"
+	"time"
+
+	"github.com/pg9182/7zplugin/z7"
+)
+
+// ErrNotArchive should be returned by InArchive.Open if the stream does not
+// contain an archive of the expected format.
+var ErrNotArchive = errors.New("not an archive")
+
+// InArchive reads an archive. A new one is created with CArcInfo.CreateInArchive
+// for every archive opened by 7-Zip. Methods will not be called concurrently.
+//
+// Methods which take a context should stop and return ctx.Err() if it is
+// cancelled, which happens if the user cancels the operation.
+type InArchive interface {
+	// Open opens the archive from r, which contains size bytes. If r does not
+	// contain an archive of this format, ErrNotArchive should be returned. The
+	// number of items and bytes read so far may be reported to p.
+	Open(ctx context.Context, r io.ReaderAt, size int64, p Progress) error
+
+	// Close closes the archive. It will be called before Open is called again,
+	// and before the InArchive is discarded.
+	Close() error
+
+	// NumItems returns the number of items in the open archive.
+	NumItems() int
+
+	// Item gets information about an item in the open archive.
+	Item(index int) (Item, error)
+
+	// Extract writes the contents of an item to w. To report a specific
+	// operation result to 7-Zip, return an ExtractError.
+	Extract(ctx context.Context, index int, w io.Writer) error
+}
+
+// Progress reports the progress of an operation to 7-Zip. If the user cancels
+// the operation, E_ABORT (which matches context.Canceled) is returned (and the
+// context for the operation is cancelled), and the handler should stop and
+// return it. The progress of Extract and Update is reported automatically as
+// data is written or read.
+type Progress interface {
+	// SetTotal sets the total number of files and bytes to be processed, if
+	// known (zero otherwise).
+	SetTotal(files, bytes uint64) error
+
+	// SetCompleted sets the number of files and bytes processed so far. Calls
+	// are rate-limited, so it can be called as often as required.
+	SetCompleted(files, bytes uint64) error
+}
+
+// InArchiveProps may be implemented by an InArchive to list the properties it
+// provides. If it isn't implemented, the item properties default to
+// DefaultItemProps.
+type InArchiveProps interface {
+	// ItemProps lists the properties which may be set on items.
+	ItemProps() []z7.PROPID
+
+	// ArcProps lists the properties of the archive itself.
+	ArcProps() []z7.PROPID
+
+	// ArcProperty gets the value of an archive property, or nil if not set.
+	ArcProperty(propID z7.PROPID) (any, error)
+}
+
+// InArchiveOpenVolumes may be implemented by an InArchive to read archives
+// which span multiple files.
+type InArchiveOpenVolumes interface {
+	// OpenVolumes is called instead of Open if the host can open other files
+	// next to the archive. Volumes opened with vol remain valid until Close.
+	OpenVolumes(ctx context.Context, r io.ReaderAt, size int64, vol Volumes, p Progress) error
+}
+
+// Volumes opens files in the same directory as an archive being opened.
+type Volumes interface {
+	// Name gets the file name of the archive, or an empty string if unknown.
+	Name() string
+
+	// OpenVolume opens another file by name. If it doesn't exist,
+	// fs.ErrNotExist is returned.
+	OpenVolume(name string) (r io.ReaderAt, size int64, err error)
+}
+
+// InArchiveGetStream may be implemented by an InArchive to allow the contents
+// of items to be read directly instead of being extracted (e.g., to browse
+// nested archives without a temporary file).
+type InArchiveGetStream interface {
+	// GetStream returns a reader for the contents of an item. If the item
+	// cannot be read directly, r should be nil.
+	GetStream(index int) (r io.ReaderAt, size int64, err error)
+}
+
+// InArchiveOpenSeq may be implemented by an InArchive to allow archives to be
+// read from a non-seekable stream (e.g., 7z x -si).
+type InArchiveOpenSeq interface {
+	// OpenSeq opens the archive from r, which can only be read sequentially.
+	// If r does not contain an archive of this format, ErrNotArchive should be
+	// returned. Close will be called as usual. NumItems and Item will not be
+	// called on archives opened with OpenSeq.
+	OpenSeq(ctx context.Context, r io.Reader) error
+
+	// Next reads the next item in the stream, returning io.EOF at the end. The
+	// contents of the item can be read from r until Next is called again.
+	Next(ctx context.Context) (it Item, r io.Reader, err error)
+}
+
+// InArchiveRawCopy may be implemented by an InArchive to allow the contents of
+// items kept when updating an archive to be copied without decompressing and
+// recompressing them. See UpdateItem.OpenRaw.
+type InArchiveRawCopy interface {
+	// RawStream returns a reader for the packed contents of an item as stored
+	// in the archive, along with any format-specific information needed to
+	// write it again (e.g., the compression method). If the item cannot be
+	// copied directly, r should be nil.
+	RawStream(index int) (r io.ReaderAt, size int64, info any, err error)
+}
+
+// InArchiveGetRawProps may be implemented by an InArchive to describe items as
+// a tree instead of by full paths, and to provide binary properties. If it is
+// implemented, kpidIsTree is reported as true unless ArcProperty overrides it.
+// If kpidNtSecure is not set, Item.NtSecure is used.
+type InArchiveGetRawProps interface {
+	// Parent gets the index of the parent of an item, or -1 if the item is at
+	// the root of the archive.
+	Parent(index int) (int, error)
+
+	// RawProps lists the raw properties which may be set on items.
+	RawProps() []z7.PROPID
+
+	// RawProperty gets the value of a raw property of an item, which must be a
+	// []byte for binary data, a string for text, or nil if not set. If
+	// kpidName is not set, the last element of the item path is used (or the
+	// stream name for alternate streams).
+	RawProperty(index int, propID z7.PROPID) (any, error)
+}
+
+// OutArchive writes an archive. A new one is created with
+// CArcInfo.CreateOutArchive for every archive created or updated by 7-Zip.
+type OutArchive interface {
+	// Update writes an archive containing items to w, which also implements
+	// io.Seeker if the output is seekable. If an item from the existing archive
+	// is kept, its contents are read from the open InArchive. If the operation
+	// is cancelled, ctx is cancelled, the iteration stops early, and the error
+	// returned by Update is ignored.
+	Update(ctx context.Context, w io.Writer, items iter.Seq[UpdateItem]) error
+}
+
+// OutArchiveVolumes may be implemented by an OutArchive which writes parts of
+// the archive to additional volumes (IArchiveUpdateCallback2).
+type OutArchiveVolumes interface {
+	OutArchive
+
+	// UpdateVolumes is like Update, but additional volumes can be created with
+	// vol. It is used instead of Update.
+	UpdateVolumes(ctx context.Context, w io.Writer, vol OutVolumes, items iter.Seq[UpdateItem]) error
+}
+
+// OutVolumes creates additional volumes for OutArchiveVolumes.
+type OutVolumes interface {
+	// CreateVolume creates the volume with the specified zero-based index. The
+	// host chooses the name of the file. If the host cannot create volumes,
+	// an error matching errors.ErrUnsupported is returned.
+	CreateVolume(index int) (io.WriteCloser, error)
+}
+
+// ErrUnavailable is returned by UpdateItem.Open if 7-Zip could not open the
+// file to be added (e.g., because it is locked), in which case the item
+// should be skipped.
+var ErrUnavailable = errors.New("data unavailable")
+
+// ErrNoRaw is returned by UpdateItem.OpenRaw if the packed contents of the item
+// cannot be copied directly.
+var ErrNoRaw = errors.New("raw data not available")
+
+// UpdateItem is an item to be written by OutArchive.Update.
+type UpdateItem struct {
+	Index       int  // the index of the item in the new archive
+	FromArchive int  // the index of the item in the existing archive, or -1 if it is new
+	NewData     bool // whether the contents are from 7-Zip rather than the existing archive
+	Props       Item // the properties of the item
+	Existing    Item // the properties of the item in the existing archive, if any
+
+	open    func() (io.ReadCloser, error)
+	openRaw func() (io.ReadCloser, any, error)
+}
+
+// Open opens the contents of the item. The reader must be closed before the
+// next item is requested.
+func (u UpdateItem) Open() (io.ReadCloser, error) {
+	return u.open()
+}
+
+// OpenRaw opens the packed contents of an unmodified item from the existing
+// archive, along with the information returned by InArchiveRawCopy.RawStream.
+// If the item is new, its contents have changed, or the InArchive doesn't
+// support it, ErrNoRaw is returned, and Open should be used instead. The
+// reader must be closed before the next item is requested.
+func (u UpdateItem) OpenRaw() (r io.ReadCloser, info any, err error) {
+	return u.openRaw()
+}
+
+// DefaultItemProps are the item properties used if an InArchive doesn't
+// implement InArchiveProps.
+var DefaultItemProps = []z7.PROPID{
+	z7.PROPID_kpidPath,
+	z7.PROPID_kpidIsDir,
+	z7.PROPID_kpidSize,
+	z7.PROPID_kpidPackSize,
+	z7.PROPID_kpidMTime,
+}
+
+// Item describes an item in an archive.
+type Item struct {
+	Path     string    // kpidPath, slash-separated
+	IsDir    bool      // kpidIsDir
+	Size     uint64    // kpidSize
+	PackSize uint64    // kpidPackSize, if non-zero
+	Attrib   uint32    // kpidAttrib, if non-zero
+	CTime    time.Time // kpidCTime, if non-zero
+	ATime    time.Time // kpidATime, if non-zero
+	MTime    time.Time // kpidMTime, if non-zero
+
+	// TimePrec is the precision of CTime, ATime and MTime, if different from
+	// the default in CArcInfo.TimePrecs.
+	TimePrec TimePrec
+
+	// Mode is kpidPosixAttrib, if non-zero. If Attrib is zero, kpidAttrib is
+	// also set to it, with the unix mode in the high 16 bits.
+	Mode fs.FileMode
+
+	// SymLink is kpidSymLink, the target of a symbolic link, if non-empty. It
+	// requires CArcInfo.Flags to contain kSymLinks.
+	SymLink string
+
+	// HardLink is kpidHardLink, the slash-separated path of the item a hard
+	// link refers to, if non-empty. It requires CArcInfo.Flags to contain
+	// kHardLinks.
+	HardLink string
+
+	// NtSecure is the kpidNtSecure raw property, a self-relative Windows
+	// SECURITY_DESCRIPTOR, if non-nil. It requires CArcInfo.Flags to contain
+	// kNtSecure.
+	NtSecure []byte
+
+	// IsAltStream is kpidIsAltStream. It requires CArcInfo.Flags to contain
+	// kAltStreams. If the InArchive implements InArchiveGetRawProps, the parent
+	// is the item the stream belongs to, and the name is the stream name.
+	// Otherwise, the path is in the form "file:stream".
+	IsAltStream bool
+
+	// Props contains additional properties. They take precedence over the
+	// fields above.
+	Props map[z7.PROPID]any
+}
+
+// Property gets the value of an item property, or nil if it isn't set.
+func (it Item) Property(propID z7.PROPID) any {
+	if v, ok := it.Props[propID]; ok {
+		return v
+	}
+	switch propID {
+	case z7.PROPID_kpidPath:
+		if it.Path != "" {
+			return it.Path
+		}
+	case z7.PROPID_kpidIsDir:
+		return it.IsDir
+	case z7.PROPID_kpidSize:
+		if !it.IsDir {
+			return it.Size
+		}
+	case z7.PROPID_kpidPackSize:
+		if it.PackSize != 0 {
+			return it.PackSize
+		}
+	case z7.PROPID_kpidAttrib:
+		if it.Attrib != 0 {
+			return it.Attrib
+		}
+		if it.Mode != 0 {
+			return unixAttrib(it.Mode)
+		}
+	case z7.PROPID_kpidPosixAttrib:
+		if it.Mode != 0 {
+			return unixMode(it.Mode)
+		}
+	case z7.PROPID_kpidSymLink:
+		if it.SymLink != "" {
+			return it.SymLink
+		}
+	case z7.PROPID_kpidHardLink:
+		if it.HardLink != "" {
+			return it.HardLink
+		}
+	case z7.PROPID_kpidCTime:
+		if !it.CTime.IsZero() {
+			return it.CTime
+		}
+	case z7.PROPID_kpidATime:
+		if !it.ATime.IsZero() {
+			return it.ATime
+		}
+	case z7.PROPID_kpidMTime:
+		if !it.MTime.IsZero() {
+			return it.MTime
+		}
+	case z7.PROPID_kpidIsAltStream:
+		if it.IsAltStream {
+			return true
+		}
+	}
+	return nil
+}
+
+// ExtractError reports a specific operation result to 7-Zip.
+type ExtractError z7.NArchive_NExtract_NOperationResult
+
+func (e ExtractError) Error() string {
+	switch z7.NArchive_NExtract_NOperationResult(e) {
+	case z7.NArchive_NExtract_NOperationResult_kOK:
+		return "ok"
+	case z7.NArchive_NExtract_NOperationResult_kUnsupportedMethod:
+		return "unsupported method"
+	case z7.NArchive_NExtract_NOperationResult_kDataError:
+		return "data error"
+	case z7.NArchive_NExtract_NOperationResult_kCRCError:
+		return "crc error"
+	case z7.NArchive_NExtract_NOperationResult_kUnavailable:
+		return "unavailable data"
+	case z7.NArchive_NExtract_NOperationResult_kUnexpectedEnd:
+		return "unexpected end of data"
+	case z7.NArchive_NExtract_NOperationResult_kDataAfterEnd:
+		return "data after end"
+	case z7.NArchive_NExtract_NOperationResult_kIsNotArc:
+		return "not an archive"
+	case z7.NArchive_NExtract_NOperationResult_kHeadersError:
+		return "headers error"
+	case z7.NArchive_NExtract_NOperationResult_kWrongPassword:
+		return "wrong password"
+	default:
+		return "operation result " + strconv.Itoa(int(e))
+	}
+}
+
+// extractResult converts an error returned by InArchive.Extract into an
+// operation result.
+func extractResult(err error) z7.NArchive_NExtract_NOperationResult {
+	var ee ExtractError
+	switch {
+	case err == nil:
+		return z7.NArchive_NExtract_NOperationResult_kOK
+	case errors.As(err, &ee):
+		return z7.NArchive_NExtract_NOperationResult(ee)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return z7.NArchive_NExtract_NOperationResult_kUnexpectedEnd
+	default:
+		return z7.NArchive_NExtract_NOperationResult_kDataError
+	}
+}
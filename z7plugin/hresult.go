@@ -0,0 +1,146 @@
+package z7plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"runtime"
+	"syscall"
+)
+
+// HRESULT is a COM result code. It implements error, so handlers can return a
+// specific code to 7-Zip (possibly wrapped), and errors returned by 7-Zip can
+// be inspected with errors.Is and errors.As.
+//
+// Errors returned by handlers are converted to a HRESULT when they are passed
+// to 7-Zip: a HRESULT in the chain is used as-is, ErrNotArchive becomes
+// S_FALSE, context.Canceled becomes E_ABORT, errors.ErrUnsupported becomes
+// E_NOTIMPL, a syscall.Errno becomes HRESULT_FROM_WIN32 (on Windows), and
+// anything else becomes E_FAIL.
+type HRESULT uint32
+
+// Common HRESULT values.
+const (
+	S_OK                      HRESULT = 0x00000000
+	S_FALSE                   HRESULT = 0x00000001
+	E_NOTIMPL                 HRESULT = 0x80004001
+	E_NOINTERFACE             HRESULT = 0x80004002
+	E_ABORT                   HRESULT = 0x80004004
+	E_FAIL                    HRESULT = 0x80004005
+	CLASS_E_CLASSNOTAVAILABLE HRESULT = 0x80040111
+	E_OUTOFMEMORY             HRESULT = 0x8007000E
+	E_INVALIDARG              HRESULT = 0x80070057
+)
+
+// Win32 error codes used by HRESULT.Is.
+const (
+	errorFileNotFound  = 2
+	errorPathNotFound  = 3
+	errorAccessDenied  = 5
+	errorFileExists    = 80
+	errorAlreadyExists = 183
+
+	facilityWin32 = 7
+)
+
+// errnoWin32 is true if syscall.Errno values are Win32 error codes.
+const errnoWin32 = runtime.GOOS == "windows"
+
+// HRESULT_FROM_WIN32 converts a Win32 error code (e.g., windows.ERROR_*) into
+// a HRESULT.
+func HRESULT_FROM_WIN32(e syscall.Errno) HRESULT {
+	if int32(e) <= 0 {
+		return HRESULT(e)
+	}
+	return HRESULT(e)&0x0000FFFF | facilityWin32<<16 | 0x80000000
+}
+
+// Failed returns true if hr is an error code. Note that S_FALSE is a success
+// code, but is still an error when returned as one.
+func (hr HRESULT) Failed() bool {
+	return int32(hr) < 0
+}
+
+// Win32 gets the Win32 error code if hr was created by HRESULT_FROM_WIN32.
+func (hr HRESULT) Win32() (syscall.Errno, bool) {
+	if hr&0xFFFF0000 == 0x80000000|facilityWin32<<16 {
+		return syscall.Errno(hr & 0xFFFF), true
+	}
+	return 0, false
+}
+
+func (hr HRESULT) Error() string {
+	switch hr {
+	case S_OK:
+		return "success"
+	case S_FALSE:
+		return "false"
+	case E_NOTIMPL:
+		return "not implemented"
+	case E_NOINTERFACE:
+		return "no such interface supported"
+	case E_ABORT:
+		return "operation aborted"
+	case E_FAIL:
+		return "unspecified error"
+	case CLASS_E_CLASSNOTAVAILABLE:
+		return "class not available"
+	case E_OUTOFMEMORY:
+		return "out of memory"
+	case E_INVALIDARG:
+		return "invalid argument"
+	}
+	if e, ok := hr.Win32(); ok {
+		return fmt.Sprintf("win32 error %d", uint32(e))
+	}
+	return fmt.Sprintf("hresult 0x%08X", uint32(hr))
+}
+
+// Is allows HRESULT values to match the equivalent Go errors (e.g.,
+// errors.Is(E_ABORT, context.Canceled)).
+func (hr HRESULT) Is(target error) bool {
+	switch target {
+	case context.Canceled:
+		return hr == E_ABORT
+	case errors.ErrUnsupported:
+		return hr == E_NOTIMPL
+	case ErrNotArchive:
+		return hr == S_FALSE
+	}
+	if e, ok := hr.Win32(); ok {
+		switch target {
+		case fs.ErrNotExist:
+			return e == errorFileNotFound || e == errorPathNotFound
+		case fs.ErrPermission:
+			return e == errorAccessDenied
+		case fs.ErrExist:
+			return e == errorFileExists || e == errorAlreadyExists
+		}
+	}
+	return false
+}
+
+// hresultOf converts an error returned by a handler into a HRESULT.
+func hresultOf(err error) HRESULT {
+	var (
+		hr    HRESULT
+		errno syscall.Errno
+	)
+	switch {
+	case err == nil:
+		return S_OK
+	case errors.As(err, &hr):
+		return hr
+	case errors.Is(err, ErrNotArchive):
+		return S_FALSE
+	case errors.Is(err, context.Canceled):
+		return E_ABORT
+	case errors.Is(err, errors.ErrUnsupported):
+		return E_NOTIMPL
+	case errors.As(err, &errno) && errnoWin32:
+		return HRESULT_FROM_WIN32(errno)
+	default:
+		return E_FAIL
+	}
+}
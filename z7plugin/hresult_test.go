@@ -0,0 +1,112 @@
+package z7plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"syscall"
+	"testing"
+
+	"github.com/pg9182/7zplugin/z7"
+)
+
+func TestHRESULTOf(t *testing.T) {
+	win32 := func(hr HRESULT) HRESULT {
+		if !errnoWin32 {
+			return E_FAIL
+		}
+		return hr
+	}
+	for _, tc := range []struct {
+		err error
+		hr  HRESULT
+	}{
+		{nil, S_OK},
+		{E_INVALIDARG, E_INVALIDARG},
+		{fmt.Errorf("wrapped: %w", E_OUTOFMEMORY), E_OUTOFMEMORY},
+		{errors.Join(io.EOF, E_NOINTERFACE), E_NOINTERFACE},
+		{ErrNotArchive, S_FALSE},
+		{fmt.Errorf("wrapped: %w", ErrNotArchive), S_FALSE},
+		{context.Canceled, E_ABORT},
+		{fmt.Errorf("wrapped: %w", context.Canceled), E_ABORT},
+		{errors.ErrUnsupported, E_NOTIMPL},
+		{syscall.Errno(errorAccessDenied), win32(0x80070005)},
+		{&fs.PathError{Op: "open", Path: "x", Err: syscall.Errno(errorFileNotFound)}, win32(0x80070002)},
+		{ExtractError(z7.NArchive_NExtract_NOperationResult_kCRCError), E_FAIL},
+		{fmt.Errorf("wrapped: %w", ExtractError(z7.NArchive_NExtract_NOperationResult_kDataError)), E_FAIL},
+		{io.ErrUnexpectedEOF, E_FAIL},
+		{errors.New("other"), E_FAIL},
+	} {
+		if hr := hresultOf(tc.err); hr != tc.hr {
+			t.Errorf("hresultOf(%v) = 0x%08X; expected 0x%08X", tc.err, uint32(hr), uint32(tc.hr))
+		}
+	}
+}
+
+func TestHRESULTFromWin32(t *testing.T) {
+	for _, tc := range []struct {
+		e  syscall.Errno
+		hr HRESULT
+	}{
+		{0, S_OK},
+		{errorFileNotFound, 0x80070002},
+		{errorAlreadyExists, 0x800700B7},
+		{0x12345, 0x80072345},
+	} {
+		hr := HRESULT_FROM_WIN32(tc.e)
+		if hr != tc.hr {
+			t.Errorf("HRESULT_FROM_WIN32(%d) = 0x%08X; expected 0x%08X", uint32(tc.e), uint32(hr), uint32(tc.hr))
+		}
+		if e, ok := hr.Win32(); ok != (tc.e != 0) || (ok && e != tc.e&0xFFFF) {
+			t.Errorf("(0x%08X).Win32() = %d, %t", uint32(hr), uint32(e), ok)
+		}
+	}
+}
+
+func TestHRESULTIs(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		target error
+		is     bool
+	}{
+		{E_ABORT, context.Canceled, true},
+		{E_FAIL, context.Canceled, false},
+		{fmt.Errorf("wrapped: %w", E_ABORT), context.Canceled, true},
+		{E_NOTIMPL, errors.ErrUnsupported, true},
+		{S_FALSE, ErrNotArchive, true},
+		{S_OK, ErrNotArchive, false},
+		{E_INVALIDARG, E_INVALIDARG, true},
+		{E_INVALIDARG, E_FAIL, false},
+		{HRESULT_FROM_WIN32(errorFileNotFound), fs.ErrNotExist, true},
+		{HRESULT_FROM_WIN32(errorPathNotFound), fs.ErrNotExist, true},
+		{HRESULT_FROM_WIN32(errorAccessDenied), fs.ErrPermission, true},
+		{HRESULT_FROM_WIN32(errorAccessDenied), fs.ErrNotExist, false},
+		{HRESULT_FROM_WIN32(errorFileExists), fs.ErrExist, true},
+		{HRESULT_FROM_WIN32(errorAlreadyExists), fs.ErrExist, true},
+		{fmt.Errorf("wrapped: %w", HRESULT_FROM_WIN32(errorFileNotFound)), fs.ErrNotExist, true},
+		{E_FAIL, fs.ErrNotExist, false},
+	} {
+		if is := errors.Is(tc.err, tc.target); is != tc.is {
+			t.Errorf("errors.Is(%v, %v) = %t; expected %t", tc.err, tc.target, is, tc.is)
+		}
+	}
+}
+
+func TestExtractResult(t *testing.T) {
+	for _, tc := range []struct {
+		err error
+		res z7.NArchive_NExtract_NOperationResult
+	}{
+		{nil, z7.NArchive_NExtract_NOperationResult_kOK},
+		{ExtractError(z7.NArchive_NExtract_NOperationResult_kCRCError), z7.NArchive_NExtract_NOperationResult_kCRCError},
+		{fmt.Errorf("wrapped: %w", ExtractError(z7.NArchive_NExtract_NOperationResult_kUnavailable)), z7.NArchive_NExtract_NOperationResult_kUnavailable},
+		{io.ErrUnexpectedEOF, z7.NArchive_NExtract_NOperationResult_kUnexpectedEnd},
+		{E_FAIL, z7.NArchive_NExtract_NOperationResult_kDataError},
+	} {
+		if res := extractResult(tc.err); res != tc.res {
+			t.Errorf("extractResult(%v) = %d; expected %d", tc.err, res, tc.res)
+		}
+	}
+}
@@ -0,0 +1,685 @@
+//go:build windows
+
+package z7plugin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/lxn/win"
+	"github.com/pg9182/7zplugin/winext"
+	"github.com/pg9182/7zplugin/z7"
+	"golang.org/x/sys/windows"
+)
+
+// CPP/7zip/Archive/IArchive.h
+
+const (
+	vtblIProgress_SetTotal                         = 3
+	vtblIProgress_SetCompleted                     = 4
+	vtblIArchiveExtractCallback_GetStream          = 5
+	vtblIArchiveExtractCallback_PrepareOperation   = 6
+	vtblIArchiveExtractCallback_SetOperationResult = 7
+)
+
+// inArchive implements IInArchive for an InArchive.
+type inArchive struct {
+	arc      *CArcInfo
+	h        InArchive
+	stream   *hostInStream                      // the open stream, if any
+	seq      *hostSequentialInStream            // the open sequential stream, if any
+	vol      *hostVolumes                       // the volumes opened with stream, if any
+	seqItems []Item                             // the items read so far from seq
+	rawProp  uintptr                            // unmanaged buffer for the last raw property
+	out      *outArchive                        // the IOutArchive, if supported
+	cancel   atomic.Pointer[context.CancelFunc] // cancels the current operation
+}
+
+var vtblInArchive = newComVtbl([]win.IID{z7.IID_IInArchive},
+	comMethod("IInArchive.Open", func(this uintptr, stream uintptr, maxCheckStartPosition *uint64, openCallback uintptr) uintptr {
+		return uintptr(comImpl[*inArchive](this).Open(comPtr(stream), maxCheckStartPosition, comPtr(openCallback)))
+	}),
+	comMethod("IInArchive.Close", func(this uintptr) uintptr {
+		return uintptr(comImpl[*inArchive](this).Close())
+	}),
+	comMethod("IInArchive.GetNumberOfItems", func(this uintptr, numItems *uint32) uintptr {
+		return uintptr(comImpl[*inArchive](this).GetNumberOfItems(numItems))
+	}),
+	comMethod("IInArchive.GetProperty", func(this uintptr, index uint32, propID winext.PROPID, value *winext.PROPVARIANT) uintptr {
+		return uintptr(comImpl[*inArchive](this).GetProperty(index, propID, value))
+	}),
+	comMethod("IInArchive.Extract", func(this uintptr, indices *uint32, numItems uint32, testMode int32, extractCallback uintptr) uintptr {
+		return uintptr(comImpl[*inArchive](this).Extract(indices, numItems, testMode, comPtr(extractCallback)))
+	}),
+	comMethod("IInArchive.GetArchiveProperty", func(this uintptr, propID winext.PROPID, value *winext.PROPVARIANT) uintptr {
+		return uintptr(comImpl[*inArchive](this).GetArchiveProperty(propID, value))
+	}),
+	comMethod("IInArchive.GetNumberOfProperties", func(this uintptr, numProps *uint32) uintptr {
+		return uintptr(comImpl[*inArchive](this).GetNumberOfProperties(numProps))
+	}),
+	comMethod("IInArchive.GetPropertyInfo", func(this uintptr, index uint32, name **uint16, propID *winext.PROPID, varType *win.VARTYPE) uintptr {
+		return uintptr(comImpl[*inArchive](this).GetPropertyInfo(index, name, propID, varType))
+	}),
+	comMethod("IInArchive.GetNumberOfArchiveProperties", func(this uintptr, numProps *uint32) uintptr {
+		return uintptr(comImpl[*inArchive](this).GetNumberOfArchiveProperties(numProps))
+	}),
+	comMethod("IInArchive.GetArchivePropertyInfo", func(this uintptr, index uint32, name **uint16, propID *winext.PROPID, varType *win.VARTYPE) uintptr {
+		return uintptr(comImpl[*inArchive](this).GetArchivePropertyInfo(index, name, propID, varType))
+	}),
+)
+
+var vtblArchiveOpenSeq = newComVtbl([]win.IID{z7.IID_IArchiveOpenSeq},
+	comMethod("IArchiveOpenSeq.OpenSeq", func(this uintptr, stream uintptr) uintptr {
+		return uintptr(comImpl[*inArchive](this).OpenSeq(comPtr(stream)))
+	}),
+)
+
+var vtblArchiveGetRawProps = newComVtbl([]win.IID{z7.IID_IArchiveGetRawProps},
+	comMethod("IArchiveGetRawProps.GetParent", func(this uintptr, index uint32, parent *uint32, parentType *uint32) uintptr {
+		return uintptr(comImpl[*inArchive](this).GetParent(index, parent, parentType))
+	}),
+	comMethod("IArchiveGetRawProps.GetRawProp", func(this uintptr, index uint32, propID winext.PROPID, data *uintptr, dataSize *uint32, propType *uint32) uintptr {
+		return uintptr(comImpl[*inArchive](this).GetRawProp(index, propID, data, dataSize, propType))
+	}),
+	comMethod("IArchiveGetRawProps.GetNumRawProps", func(this uintptr, numProps *uint32) uintptr {
+		return uintptr(comImpl[*inArchive](this).GetNumRawProps(numProps))
+	}),
+	comMethod("IArchiveGetRawProps.GetRawPropInfo", func(this uintptr, index uint32, name **uint16, propID *winext.PROPID) uintptr {
+		return uintptr(comImpl[*inArchive](this).GetRawPropInfo(index, name, propID))
+	}),
+)
+
+var vtblInArchiveGetStream = newComVtbl([]win.IID{z7.IID_IInArchiveGetStream},
+	comMethod("IInArchiveGetStream.GetStream", func(this uintptr, index uint32, stream *uintptr) uintptr {
+		return uintptr(comImpl[*inArchive](this).GetStream(index, stream))
+	}),
+)
+
+// newInArchive creates a new IInArchive object for the format.
+func newInArchive(arc *CArcInfo) *comObject {
+	a := &inArchive{arc: arc, h: arc.CreateInArchive()}
+	vtbl := []*comVtbl{vtblInArchive}
+	if _, ok := a.h.(InArchiveGetStream); ok {
+		vtbl = append(vtbl, vtblInArchiveGetStream)
+	}
+	if _, ok := a.h.(InArchiveOpenSeq); ok {
+		vtbl = append(vtbl, vtblArchiveOpenSeq)
+	}
+	if _, ok := a.h.(InArchiveGetRawProps); ok || arc.Flags&z7.NArchive_NArcInfoFlags_kNtSecure != 0 {
+		vtbl = append(vtbl, vtblArchiveGetRawProps)
+	}
+	if arc.CreateOutArchive != nil {
+		a.out = &outArchive{arc: arc, in: a}
+		vtbl = append(vtbl, vtblOutArchive)
+	}
+	return newComObject(a, vtbl...)
+}
+
+func (a *inArchive) outArchive() *outArchive {
+	return a.out
+}
+
+func (a *inArchive) comRelease() {
+	a.Close()
+	a.freeRawProp()
+}
+
+// begin starts an operation, returning a context which is cancelled if the host
+// returns E_ABORT. The returned function must be called when it ends.
+func (a *inArchive) begin() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel.Store(&cancel)
+	return ctx, func() {
+		a.cancel.Store(nil)
+		cancel()
+	}
+}
+
+// abort cancels the current operation, if any.
+func (a *inArchive) abort() {
+	if cancel := a.cancel.Load(); cancel != nil {
+		(*cancel)()
+	}
+}
+
+func (a *inArchive) itemProps() []z7.PROPID {
+	if p, ok := a.h.(InArchiveProps); ok {
+		return p.ItemProps()
+	}
+	return DefaultItemProps
+}
+
+func (a *inArchive) arcProps() []z7.PROPID {
+	if p, ok := a.h.(InArchiveProps); ok {
+		return p.ArcProps()
+	}
+	return nil
+}
+
+func (a *inArchive) Open(stream comPtr, maxCheckStartPosition *uint64, openCallback comPtr) winext.HRESULT {
+	a.Close()
+
+	ctx, end := a.begin()
+	defer end()
+
+	s := newHostInStream(stream)
+	s.abort = a.abort
+	size, err := s.Size()
+	if err != nil {
+		s.Release()
+		return errorHRESULT(err)
+	}
+	prog := newHostProgress(openCallback, true, a.abort)
+	if o, ok := a.h.(InArchiveOpenVolumes); ok && openCallback != 0 {
+		if p := openCallback.QueryInterface(z7.IID_IArchiveOpenVolumeCallback); p != 0 {
+			a.vol = newHostVolumes(p, a.abort)
+			p.Release()
+			err = o.OpenVolumes(ctx, s, size, a.vol, prog)
+		} else {
+			err = a.h.Open(ctx, s, size, prog)
+		}
+	} else {
+		err = a.h.Open(ctx, s, size, prog)
+	}
+	if err != nil {
+		a.h.Close()
+		s.Release()
+		if a.vol != nil {
+			a.vol.Release()
+			a.vol = nil
+		}
+		return errorHRESULT(err)
+	}
+	a.stream = s
+	return win.S_OK
+}
+
+func (a *inArchive) OpenSeq(stream comPtr) winext.HRESULT {
+	a.Close()
+
+	ctx, end := a.begin()
+	defer end()
+
+	s := newHostSequentialInStream(stream, a.abort)
+	if err := a.h.(InArchiveOpenSeq).OpenSeq(ctx, s); err != nil {
+		a.h.Close()
+		s.Release()
+		return errorHRESULT(err)
+	}
+	a.seq = s
+	return win.S_OK
+}
+
+func (a *inArchive) Close() winext.HRESULT {
+	if a.stream == nil && a.seq == nil {
+		return win.S_OK
+	}
+	err := a.h.Close()
+	if a.stream != nil {
+		a.stream.Release()
+		a.stream = nil
+	}
+	if a.vol != nil {
+		a.vol.Release()
+		a.vol = nil
+	}
+	if a.seq != nil {
+		a.seq.Release()
+		a.seq = nil
+		a.seqItems = nil
+	}
+	return errorHRESULT(err)
+}
+
+func (a *inArchive) numItems() int {
+	if a.seq != nil {
+		return len(a.seqItems)
+	}
+	return a.h.NumItems()
+}
+
+func (a *inArchive) item(index uint32) (Item, error) {
+	if a.seq != nil {
+		return a.seqItems[index], nil
+	}
+	return a.h.Item(int(index))
+}
+
+func (a *inArchive) GetNumberOfItems(numItems *uint32) winext.HRESULT {
+	*numItems = uint32(a.numItems())
+	return win.S_OK
+}
+
+func (a *inArchive) GetProperty(index uint32, propID winext.PROPID, value *winext.PROPVARIANT) winext.HRESULT {
+	value.Vt = win.VT_EMPTY
+	if int(index) >= a.numItems() {
+		return win.E_INVALIDARG
+	}
+	it, err := a.item(index)
+	if err != nil {
+		return errorHRESULT(err)
+	}
+	x := it.Property(propID)
+	if t, ok := x.(time.Time); ok {
+		prec := it.TimePrec
+		if prec == TimePrecUnknown {
+			prec = a.arc.TimePrec()
+		}
+		x = propTime{t, prec}
+	}
+	return setProp(value, propID, x)
+}
+
+func (a *inArchive) Extract(indices *uint32, numItems uint32, testMode int32, extractCallback comPtr) winext.HRESULT {
+	ctx, end := a.begin()
+	defer end()
+
+	if a.seq != nil {
+		return a.extractSeq(ctx, indices, numItems, testMode, extractCallback)
+	}
+
+	var index []uint32
+	if numItems == math.MaxUint32 {
+		index = make([]uint32, a.h.NumItems())
+		for i := range index {
+			index[i] = uint32(i)
+		}
+	} else {
+		index = unsafe.Slice(indices, numItems)
+	}
+
+	var total uint64
+	items := make([]Item, len(index))
+	for k, i := range index {
+		if int(i) >= a.h.NumItems() {
+			return win.E_INVALIDARG
+		}
+		it, err := a.h.Item(int(i))
+		if err != nil {
+			return errorHRESULT(err)
+		}
+		if !it.IsDir {
+			total += it.Size
+		}
+		items[k] = it
+	}
+	prog := newHostProgress(extractCallback, false, a.abort)
+	if err := prog.SetTotal(0, total); err != nil {
+		return errorHRESULT(err)
+	}
+
+	var completed uint64
+	for k, i := range index {
+		if err := prog.setCompleted(0, completed); err != nil {
+			return errorHRESULT(err)
+		}
+		it := items[k]
+		if hr := extractItem(extractCallback, i, it.IsDir, testMode, a.abort, func(w io.Writer) error {
+			return a.h.Extract(ctx, int(i), w)
+		}, func(n uint64) error {
+			return prog.SetCompleted(0, completed+n)
+		}); hr != win.S_OK {
+			return hr
+		}
+		if !it.IsDir {
+			completed += it.Size
+		}
+	}
+	return win.S_OK
+}
+
+// extractSeq is like Extract, but for archives opened with OpenSeq. Only the
+// items which haven't been read yet can be extracted.
+func (a *inArchive) extractSeq(ctx context.Context, indices *uint32, numItems uint32, testMode int32, extractCallback comPtr) winext.HRESULT {
+	var want map[uint32]bool
+	if numItems != math.MaxUint32 {
+		want = make(map[uint32]bool, numItems)
+		for _, i := range unsafe.Slice(indices, numItems) {
+			want[i] = true
+		}
+	}
+	prog := newHostProgress(extractCallback, false, a.abort)
+	for {
+		if err := prog.setCompleted(0, uint64(a.seq.n)); err != nil {
+			return errorHRESULT(err)
+		}
+		if want != nil && len(want) == 0 {
+			return win.S_OK
+		}
+		it, r, err := a.h.(InArchiveOpenSeq).Next(ctx)
+		if err != nil {
+			if err == io.EOF {
+				return win.S_OK
+			}
+			return errorHRESULT(err)
+		}
+		i := uint32(len(a.seqItems))
+		a.seqItems = append(a.seqItems, it)
+		if want != nil {
+			if !want[i] {
+				continue
+			}
+			delete(want, i)
+		}
+		if hr := extractItem(extractCallback, i, it.IsDir, testMode, a.abort, func(w io.Writer) error {
+			_, err := io.Copy(w, r)
+			return err
+		}, func(uint64) error {
+			return prog.SetCompleted(0, uint64(a.seq.n))
+		}); hr != win.S_OK {
+			return hr
+		}
+	}
+}
+
+// extractItem extracts a single item with IArchiveExtractCallback, using
+// extract to write its contents, and calling progress with the number of bytes
+// written so far. If the host returns E_ABORT while writing, abort is called.
+func extractItem(extractCallback comPtr, index uint32, isDir bool, testMode int32, abort func(), extract func(w io.Writer) error, progress func(n uint64) error) winext.HRESULT {
+	askMode := z7.NArchive_NExtract_NAskMode_kExtract
+	if testMode != 0 {
+		askMode = z7.NArchive_NExtract_NAskMode_kTest
+	}
+
+	var out uintptr
+	if hr := extractCallback.call(vtblIArchiveExtractCallback_GetStream, uintptr(index), uintptr(unsafe.Pointer(&out)), uintptr(askMode)); hr != win.S_OK {
+		return hr
+	}
+	if out == 0 && testMode == 0 {
+		return win.S_OK
+	}
+
+	if hr := extractCallback.call(vtblIArchiveExtractCallback_PrepareOperation, uintptr(askMode)); hr != win.S_OK {
+		if out != 0 {
+			comPtr(out).Release()
+		}
+		return hr
+	}
+
+	opRes := z7.NArchive_NExtract_NOperationResult_kOK
+	if !isDir {
+		w := &hostWriter{w: io.Discard, progress: progress}
+		if out != 0 {
+			w.w = hostSequentialOutStream{comPtr(out), abort}
+		}
+		err := extract(w)
+		if out != 0 {
+			comPtr(out).Release()
+		}
+		if w.err != nil {
+			return errorHRESULT(w.err)
+		}
+		if errors.Is(err, context.Canceled) {
+			return win.E_ABORT
+		}
+		opRes = extractResult(err)
+	} else if out != 0 {
+		comPtr(out).Release()
+	}
+
+	return extractCallback.call(vtblIArchiveExtractCallback_SetOperationResult, uintptr(opRes))
+}
+
+// hostWriter wraps an io.Writer, keeping track of the first error so it can be
+// distinguished from errors from the handler, and reporting progress.
+type hostWriter struct {
+	w        io.Writer
+	n        uint64
+	err      error
+	progress func(n uint64) error
+}
+
+func (w *hostWriter) Write(b []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(b)
+	w.n += uint64(n)
+	if err == nil {
+		err = w.progress(w.n)
+	}
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+func (a *inArchive) GetArchiveProperty(propID winext.PROPID, value *winext.PROPVARIANT) winext.HRESULT {
+	value.Vt = win.VT_EMPTY
+	var x any
+	if p, ok := a.h.(InArchiveProps); ok {
+		var err error
+		if x, err = p.ArcProperty(propID); err != nil {
+			return errorHRESULT(err)
+		}
+	}
+	if x == nil {
+		switch propID {
+		case z7.PROPID_kpidIsTree:
+			if _, ok := a.h.(InArchiveGetRawProps); ok {
+				x = true
+			}
+		case z7.PROPID_kpidTimeType:
+			if t := a.arc.TimePrec().fileTimeType(); t != z7.NFileTimeType_kNotDefined {
+				x = uint32(t)
+			}
+		}
+	}
+	return setProp(value, propID, x)
+}
+
+func (a *inArchive) GetNumberOfProperties(numProps *uint32) winext.HRESULT {
+	*numProps = uint32(len(a.itemProps()))
+	return win.S_OK
+}
+
+func (a *inArchive) GetPropertyInfo(index uint32, name **uint16, propID *winext.PROPID, varType *win.VARTYPE) winext.HRESULT {
+	return getPropInfo(a.itemProps(), index, name, propID, varType)
+}
+
+func (a *inArchive) GetNumberOfArchiveProperties(numProps *uint32) winext.HRESULT {
+	*numProps = uint32(len(a.arcProps()))
+	return win.S_OK
+}
+
+func (a *inArchive) GetArchivePropertyInfo(index uint32, name **uint16, propID *winext.PROPID, varType *win.VARTYPE) winext.HRESULT {
+	return getPropInfo(a.arcProps(), index, name, propID, varType)
+}
+
+func getPropInfo(props []z7.PROPID, index uint32, name **uint16, propID *winext.PROPID, varType *win.VARTYPE) winext.HRESULT {
+	*name = nil
+	if int(index) >= len(props) {
+		return win.E_INVALIDARG
+	}
+	*propID = props[index]
+	*varType = propVarTypes[props[index]]
+	return win.S_OK
+}
+
+func (a *inArchive) GetStream(index uint32, stream *uintptr) winext.HRESULT {
+	*stream = 0
+	if a.seq != nil {
+		return win.S_FALSE
+	}
+	if int(index) >= a.h.NumItems() {
+		return win.E_INVALIDARG
+	}
+	r, size, err := a.h.(InArchiveGetStream).GetStream(int(index))
+	if err != nil {
+		return errorHRESULT(err)
+	}
+	if r == nil {
+		return win.S_FALSE
+	}
+	s := NewInStream(r, size)
+	defer s.Release()
+	*stream = s.IInStream()
+	return win.S_OK
+}
+
+func (a *inArchive) GetParent(index uint32, parent *uint32, parentType *uint32) winext.HRESULT {
+	*parent = math.MaxUint32
+	*parentType = z7.NParentType_kDir
+	if a.seq != nil {
+		return win.S_OK
+	}
+	if int(index) >= a.h.NumItems() {
+		return win.E_INVALIDARG
+	}
+	t, ok := a.h.(InArchiveGetRawProps)
+	if !ok {
+		return win.S_OK
+	}
+	p, err := t.Parent(int(index))
+	if err != nil {
+		return errorHRESULT(err)
+	}
+	if p >= 0 {
+		*parent = uint32(p)
+	}
+	it, err := a.h.Item(int(index))
+	if err != nil {
+		return errorHRESULT(err)
+	}
+	if it.IsAltStream {
+		*parentType = z7.NParentType_kAltStream
+	}
+	return win.S_OK
+}
+
+func (a *inArchive) GetRawProp(index uint32, propID winext.PROPID, data *uintptr, dataSize *uint32, propType *uint32) winext.HRESULT {
+	*data = 0
+	*dataSize = 0
+	*propType = z7.NPropDataType_kNotDefined
+	if a.seq != nil {
+		return win.S_OK
+	}
+	if int(index) >= a.h.NumItems() {
+		return win.E_INVALIDARG
+	}
+	var x any
+	t, tree := a.h.(InArchiveGetRawProps)
+	if tree {
+		var err error
+		if x, err = t.RawProperty(int(index), propID); err != nil {
+			return errorHRESULT(err)
+		}
+	}
+	if x == nil && (propID == z7.PROPID_kpidNtSecure || (tree && propID == z7.PROPID_kpidName)) {
+		it, err := a.h.Item(int(index))
+		if err != nil {
+			return errorHRESULT(err)
+		}
+		switch propID {
+		case z7.PROPID_kpidNtSecure:
+			if it.NtSecure != nil {
+				x = it.NtSecure
+			}
+		case z7.PROPID_kpidName:
+			if x = path.Base(it.Path); it.IsAltStream {
+				if i := strings.LastIndexByte(it.Path, ':'); i != -1 {
+					x = it.Path[i+1:]
+				}
+			}
+		}
+	}
+
+	var b []byte
+	switch x := x.(type) {
+	case nil:
+		return win.S_OK
+	case []byte:
+		b = x
+		*propType = z7.NPropDataType_kRaw
+	case string:
+		u, err := windows.UTF16FromString(x)
+		if err != nil {
+			return win.E_INVALIDARG
+		}
+		b = unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(u))), len(u)*2)
+		*propType = z7.NPropDataType_kUtf16z
+	default:
+		return win.E_INVALIDARG
+	}
+
+	// the data must stay valid until the next call
+	a.freeRawProp()
+	if len(b) != 0 {
+		p, err := windows.LocalAlloc(windows.LMEM_FIXED, uint32(len(b)))
+		if err != nil {
+			return win.E_OUTOFMEMORY
+		}
+		copy(unsafe.Slice((*byte)(unsafe.Pointer(p)), len(b)), b)
+		a.rawProp = p
+	}
+	*data = a.rawProp
+	*dataSize = uint32(len(b))
+	return win.S_OK
+}
+
+func (a *inArchive) freeRawProp() {
+	if a.rawProp != 0 {
+		windows.LocalFree(windows.Handle(a.rawProp))
+		a.rawProp = 0
+	}
+}
+
+func (a *inArchive) rawProps() []z7.PROPID {
+	var props []z7.PROPID
+	if t, ok := a.h.(InArchiveGetRawProps); ok {
+		props = t.RawProps()
+	}
+	if a.arc.Flags&z7.NArchive_NArcInfoFlags_kNtSecure != 0 && !slices.Contains(props, z7.PROPID_kpidNtSecure) {
+		props = append(slices.Clip(props), z7.PROPID_kpidNtSecure)
+	}
+	return props
+}
+
+func (a *inArchive) GetNumRawProps(numProps *uint32) winext.HRESULT {
+	*numProps = uint32(len(a.rawProps()))
+	return win.S_OK
+}
+
+func (a *inArchive) GetRawPropInfo(index uint32, name **uint16, propID *winext.PROPID) winext.HRESULT {
+	*name = nil
+	props := a.rawProps()
+	if int(index) >= len(props) {
+		return win.E_INVALIDARG
+	}
+	*propID = props[index]
+	return win.S_OK
+}
+
+const (
+	vtblIArchiveGetRawProps_GetRawProp = 4
+)
+
+// hostGetRawProps wraps an IArchiveGetRawProps from the host. The update
+// callback implements it to provide kpidNtSecure when 7-Zip is run with -sni.
+type hostGetRawProps struct {
+	p comPtr
+}
+
+// RawProperty gets a copy of a raw property, returning nil if it isn't set.
+func (r hostGetRawProps) RawProperty(index uint32, propID z7.PROPID) ([]byte, error) {
+	var (
+		data     uintptr
+		dataSize uint32
+		propType uint32
+	)
+	if hr := r.p.call(vtblIArchiveGetRawProps_GetRawProp, uintptr(index), uintptr(propID), uintptr(unsafe.Pointer(&data)), uintptr(unsafe.Pointer(&dataSize)), uintptr(unsafe.Pointer(&propType))); hr != win.S_OK {
+		return nil, HRESULT(hr)
+	}
+	if data == 0 || propType == z7.NPropDataType_kNotDefined {
+		return nil, nil
+	}
+	return slices.Clone(unsafe.Slice((*byte)(unsafe.Pointer(data)), dataSize)), nil
+}
@@ -49,14 +49,14 @@ var Archive2 struct {
 	GetModuleProp func(propID winext.PROPID, value *winext.PROPVARIANT) winext.HRESULT
 }
 
-type Func_IsArc uintptr // syscall.NewCallback: func(p *byte, size uintptr) HRESULT
+type Func_IsArc uintptr // syscall.NewCallback: func(p *byte, size uintptr) uintptr (UInt32)
 
 func Func_IsArc_Wrap(fn func(b []byte) z7.NArchive_k_IsArc_Res) Func_IsArc {
 	if fn == nil {
 		return 0
 	}
-	return Func_IsArc(syscall.NewCallback(func(p *byte, size uintptr) z7.NArchive_k_IsArc_Res {
-		return fn(unsafe.Slice(p, int(size)))
+	return Func_IsArc(syscall.NewCallback(func(p *byte, size uintptr) uintptr {
+		return uintptr(fn(unsafe.Slice(p, int(size))))
 	}))
 }
 
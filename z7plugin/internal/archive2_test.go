@@ -0,0 +1,51 @@
+//go:build windows
+
+package internal
+
+import (
+	"bytes"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/pg9182/7zplugin/z7"
+)
+
+func TestFuncIsArcWrap(t *testing.T) {
+	if Func_IsArc_Wrap(nil) != 0 {
+		t.Errorf("expected no callback for a nil func")
+	}
+	var got []byte
+	fn := Func_IsArc_Wrap(func(b []byte) z7.NArchive_k_IsArc_Res {
+		got = append(got[:0], b...)
+		switch {
+		case len(b) < 2:
+			return z7.NArchive_k_IsArc_Res_NEED_MORE
+		case string(b[:2]) == "PK":
+			return z7.NArchive_k_IsArc_Res_YES
+		default:
+			return z7.NArchive_k_IsArc_Res_NO
+		}
+	})
+	for _, tc := range []struct {
+		b    []byte
+		want z7.NArchive_k_IsArc_Res
+	}{
+		{nil, z7.NArchive_k_IsArc_Res_NEED_MORE},
+		{[]byte("P"), z7.NArchive_k_IsArc_Res_NEED_MORE},
+		{[]byte("PK\x03\x04"), z7.NArchive_k_IsArc_Res_YES},
+		{[]byte("7z\xbc\xaf"), z7.NArchive_k_IsArc_Res_NO},
+	} {
+		var p *byte
+		if len(tc.b) != 0 {
+			p = &tc.b[0]
+		}
+		r, _, _ := syscall.SyscallN(uintptr(fn), uintptr(unsafe.Pointer(p)), uintptr(len(tc.b)))
+		if r != uintptr(tc.want) {
+			t.Errorf("IsArc(%q) = %#x, expected %#x", tc.b, r, tc.want)
+		}
+		if !bytes.Equal(got, tc.b) {
+			t.Errorf("IsArc(%q): callback got %q", tc.b, got)
+		}
+	}
+}
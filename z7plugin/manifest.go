@@ -0,0 +1,105 @@
+package z7plugin
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// Manifest describes the registered formats, codecs and hashers. It is
+// generated by the 7zplugin command on the build host and saved beside the DLL
+// as JSON.
+type Manifest struct {
+	Formats []ManifestFormat `json:"formats"`
+	Codecs  []ManifestCodec  `json:"codecs"`
+	Hashers []ManifestHasher `json:"hashers"`
+}
+
+// ManifestFormat describes a registered CArcInfo.
+type ManifestFormat struct {
+	Name            string   `json:"name"`
+	CLSID           string   `json:"clsid"`
+	Ext             []string `json:"ext"`
+	AddExt          []string `json:"addExt,omitempty"`
+	Signatures      []string `json:"signatures,omitempty"` // hex
+	SignatureOffset uint16   `json:"signatureOffset"`
+	Flags           []string `json:"flags"`
+	TimeFlags       uint32   `json:"timeFlags"`
+	IsArc           bool     `json:"isArc"`
+	Update          bool     `json:"update"`
+}
+
+// ManifestCodec describes a registered codec. Codecs aren't supported yet.
+type ManifestCodec struct {
+	Name string `json:"name"`
+	ID   uint64 `json:"id"`
+}
+
+// ManifestHasher describes a registered hasher. Hashers aren't supported yet.
+type ManifestHasher struct {
+	Name       string `json:"name"`
+	ID         uint64 `json:"id"`
+	DigestSize uint32 `json:"digestSize"`
+}
+
+// arcInfoFlags contains the names of the NArchive_NArcInfoFlags bits.
+var arcInfoFlags = []string{
+	"KeepName",
+	"AltStreams",
+	"NtSecure",
+	"FindSignature",
+	"MultiSignature",
+	"UseGlobalOffset",
+	"StartOpen",
+	"PureStartOpen",
+	"BackwardOpen",
+	"PreArc",
+	"SymLinks",
+	"HardLinks",
+	"ByExtOnlyOpen",
+	"HashHandler",
+	"CTime",
+	"CTime_Default",
+	"ATime",
+	"ATime_Default",
+	"MTime",
+	"MTime_Default",
+}
+
+// GetManifest describes the registered formats, codecs and hashers.
+func GetManifest() Manifest {
+	m := Manifest{
+		Formats: []ManifestFormat{},
+		Codecs:  []ManifestCodec{},
+		Hashers: []ManifestHasher{},
+	}
+	for _, arc := range _Arcs {
+		f := ManifestFormat{
+			Name:            arc.Name,
+			CLSID:           arc.CLSID.String(),
+			Ext:             strings.Fields(arc.Ext),
+			AddExt:          strings.Fields(arc.AddExt),
+			SignatureOffset: arc.SignatureOffset,
+			Flags:           []string{},
+			TimeFlags:       arc.TimeFlags | timeFlags(arc.TimePrecs),
+			IsArc:           arc.IsArc != nil,
+			Update:          arc.CreateOutArchive != nil,
+		}
+		for i, x := range arcInfoFlags {
+			if arc.Flags&(1<<i) != 0 {
+				f.Flags = append(f.Flags, x)
+			}
+		}
+		if sig := arc.Signature; arc.IsMultiSignature() {
+			// each signature is prefixed by its length
+			for len(sig) != 0 {
+				n := min(int(sig[0]), len(sig)-1)
+				f.Signatures = append(f.Signatures, hex.EncodeToString([]byte(sig[1:1+n])))
+				sig = sig[1+n:]
+			}
+		} else if sig != "" {
+			f.Signatures = append(f.Signatures, hex.EncodeToString([]byte(sig)))
+		}
+		m.Formats = append(m.Formats, f)
+	}
+	return m
+}
@@ -0,0 +1,100 @@
+package z7plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/pg9182/7zplugin/z7"
+)
+
+func TestGetManifest(t *testing.T) {
+	defer func(arcs []*CArcInfo) { _Arcs = arcs }(_Arcs)
+
+	_Arcs = nil
+	if b, err := json.Marshal(GetManifest()); err != nil {
+		t.Fatal(err)
+	} else if w := `{"formats":[],"codecs":[],"hashers":[]}`; string(b) != w {
+		t.Errorf("incorrect empty manifest json %s, expected %s", b, w)
+	}
+
+	_Arcs = []*CArcInfo{
+		{
+			Name:            "Single",
+			CLSID:           z7.MustGUID("{23170F69-40C1-278A-1000-000110FF0000}"),
+			Ext:             "sgl  s ",
+			Signature:       "AB\x00",
+			SignatureOffset: 2,
+			Flags:           z7.NArchive_NArcInfoFlags_kKeepName | z7.NArchive_NArcInfoFlags_kMTime,
+			TimePrecs:       []TimePrec{TimePrecUnix},
+			IsArc:           func(b []byte) z7.NArchive_k_IsArc_Res { return z7.NArchive_k_IsArc_Res_YES },
+		},
+		{
+			Name:             "Multi",
+			CLSID:            z7.MustGUID("{23170F69-40C1-278A-1000-000110FE0000}"),
+			Ext:              "mlt",
+			AddExt:           "* .tar",
+			Signature:        "\x02AB\x01C\x05DE", // the last one is truncated
+			Flags:            z7.NArchive_NArcInfoFlags_kMultiSignature,
+			TimeFlags:        1,
+			CreateInArchive:  func() InArchive { return nil },
+			CreateOutArchive: func() OutArchive { return nil },
+		},
+	}
+	b, err := json.MarshalIndent(GetManifest(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{
+  "formats": [
+    {
+      "name": "Single",
+      "clsid": "{23170F69-40C1-278A-1000-000110FF0000}",
+      "ext": [
+        "sgl",
+        "s"
+      ],
+      "signatures": [
+        "414200"
+      ],
+      "signatureOffset": 2,
+      "flags": [
+        "KeepName",
+        "MTime"
+      ],
+      "timeFlags": %d,
+      "isArc": true,
+      "update": false
+    },
+    {
+      "name": "Multi",
+      "clsid": "{23170F69-40C1-278A-1000-000110FE0000}",
+      "ext": [
+        "mlt"
+      ],
+      "addExt": [
+        "*",
+        ".tar"
+      ],
+      "signatures": [
+        "4142",
+        "43",
+        "4445"
+      ],
+      "signatureOffset": 0,
+      "flags": [
+        "MultiSignature"
+      ],
+      "timeFlags": 1,
+      "isArc": false,
+      "update": true
+    }
+  ],
+  "codecs": [],
+  "hashers": []
+}`
+	if w := fmt.Sprintf(want, timeFlags([]TimePrec{TimePrecUnix})); !bytes.Equal(b, []byte(w)) {
+		t.Errorf("incorrect manifest json:\n%s\nexpected:\n%s", b, w)
+	}
+}
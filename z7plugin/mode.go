@@ -0,0 +1,95 @@
+package z7plugin
+
+import (
+	"io/fs"
+
+	"github.com/pg9182/7zplugin/z7"
+)
+
+// unix st_mode bits
+const (
+	s_IFMT   = 0170000
+	s_IFSOCK = 0140000
+	s_IFLNK  = 0120000
+	s_IFREG  = 0100000
+	s_IFBLK  = 0060000
+	s_IFDIR  = 0040000
+	s_IFCHR  = 0020000
+	s_IFIFO  = 0010000
+	s_ISUID  = 0004000
+	s_ISGID  = 0002000
+	s_ISVTX  = 0001000
+)
+
+// unixMode converts m into a unix st_mode (i.e., kpidPosixAttrib).
+func unixMode(m fs.FileMode) uint32 {
+	x := uint32(m.Perm())
+	switch m.Type() {
+	case fs.ModeDir:
+		x |= s_IFDIR
+	case fs.ModeSymlink:
+		x |= s_IFLNK
+	case fs.ModeNamedPipe:
+		x |= s_IFIFO
+	case fs.ModeSocket:
+		x |= s_IFSOCK
+	case fs.ModeDevice:
+		x |= s_IFBLK
+	case fs.ModeDevice | fs.ModeCharDevice:
+		x |= s_IFCHR
+	default:
+		x |= s_IFREG
+	}
+	if m&fs.ModeSetuid != 0 {
+		x |= s_ISUID
+	}
+	if m&fs.ModeSetgid != 0 {
+		x |= s_ISGID
+	}
+	if m&fs.ModeSticky != 0 {
+		x |= s_ISVTX
+	}
+	return x
+}
+
+// unixAttrib converts m into windows file attributes with the unix st_mode in
+// the high 16 bits (i.e., kpidAttrib).
+func unixAttrib(m fs.FileMode) uint32 {
+	x := unixMode(m)<<16 | z7.FILE_ATTRIBUTE_UNIX_EXTENSION
+	if m.IsDir() {
+		x |= z7.FILE_ATTRIBUTE_DIRECTORY
+	}
+	if m.Perm()&0222 == 0 {
+		x |= z7.FILE_ATTRIBUTE_READONLY
+	}
+	return x
+}
+
+// fileMode converts a unix st_mode into m.
+func fileMode(x uint32) fs.FileMode {
+	m := fs.FileMode(x & 0777)
+	switch x & s_IFMT {
+	case s_IFDIR:
+		m |= fs.ModeDir
+	case s_IFLNK:
+		m |= fs.ModeSymlink
+	case s_IFIFO:
+		m |= fs.ModeNamedPipe
+	case s_IFSOCK:
+		m |= fs.ModeSocket
+	case s_IFBLK:
+		m |= fs.ModeDevice
+	case s_IFCHR:
+		m |= fs.ModeDevice | fs.ModeCharDevice
+	}
+	if x&s_ISUID != 0 {
+		m |= fs.ModeSetuid
+	}
+	if x&s_ISGID != 0 {
+		m |= fs.ModeSetgid
+	}
+	if x&s_ISVTX != 0 {
+		m |= fs.ModeSticky
+	}
+	return m
+}
@@ -0,0 +1,41 @@
+package z7plugin
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/pg9182/7zplugin/z7"
+)
+
+func TestUnixMode(t *testing.T) {
+	for _, tc := range []struct {
+		m      fs.FileMode
+		mode   uint32
+		attrib uint32 // without the mode and FILE_ATTRIBUTE_UNIX_EXTENSION
+	}{
+		{0644, 0100644, 0},
+		{0444, 0100444, z7.FILE_ATTRIBUTE_READONLY},
+		{0755 | fs.ModeDir, 0040755, z7.FILE_ATTRIBUTE_DIRECTORY},
+		{0555 | fs.ModeDir, 0040555, z7.FILE_ATTRIBUTE_DIRECTORY | z7.FILE_ATTRIBUTE_READONLY},
+		{0777 | fs.ModeSymlink, 0120777, 0},
+		{0644 | fs.ModeNamedPipe, 0010644, 0},
+		{0755 | fs.ModeSocket, 0140755, 0},
+		{0660 | fs.ModeDevice, 0060660, 0},
+		{0620 | fs.ModeDevice | fs.ModeCharDevice, 0020620, 0},
+		{0755 | fs.ModeSetuid | fs.ModeSetgid, 0106755, 0},
+		{0777 | fs.ModeDir | fs.ModeSticky, 0041777, z7.FILE_ATTRIBUTE_DIRECTORY},
+	} {
+		if x := unixMode(tc.m); x != tc.mode {
+			t.Errorf("unixMode(%v) = %#o, expected %#o", tc.m, x, tc.mode)
+		}
+		if x := tc.mode<<16 | z7.FILE_ATTRIBUTE_UNIX_EXTENSION | tc.attrib; unixAttrib(tc.m) != x {
+			t.Errorf("unixAttrib(%v) = %#x, expected %#x", tc.m, unixAttrib(tc.m), x)
+		}
+		if m := fileMode(tc.mode); m != tc.m {
+			t.Errorf("fileMode(%#o) = %v, expected %v", tc.mode, m, tc.m)
+		}
+		if m := fileMode(unixAttrib(tc.m) >> 16); m != tc.m {
+			t.Errorf("fileMode(unixAttrib(%v) >> 16) = %v", tc.m, m)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+//go:build windows
+
+package z7plugin
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/pg9182/7zplugin/winext"
+	"golang.org/x/sys/windows"
+)
+
+// modulePath gets the path of the plugin DLL.
+func modulePath() (string, error) {
+	var h windows.Handle
+	addr := reflect.ValueOf(modulePath).Pointer() // any address inside the DLL
+	if err := windows.GetModuleHandleEx(windows.GET_MODULE_HANDLE_EX_FLAG_FROM_ADDRESS|windows.GET_MODULE_HANDLE_EX_FLAG_UNCHANGED_REFCOUNT, (*uint16)(unsafe.Pointer(addr)), &h); err != nil {
+		return "", err
+	}
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	n, err := windows.GetModuleFileName(h, &buf[0], uint32(len(buf)))
+	if err != nil {
+		return "", err
+	}
+	return windows.UTF16ToString(buf[:n]), nil
+}
+
+// loadModuleSettings loads the settings file for the DLL if it exists.
+var loadModuleSettings = sync.OnceFunc(func() {
+	name := os.Getenv("Z7PLUGIN_SETTINGS")
+	if name == "" {
+		p, err := modulePath()
+		if err != nil {
+			return
+		}
+		name = strings.TrimSuffix(p, filepath.Ext(p)) + ".settings.json"
+	}
+	f, err := os.Open(name)
+	if err == nil {
+		defer f.Close()
+		err = LoadSettings(f)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		winext.OutputDebugString("z7plugin: load settings from " + name + ": " + err.Error() + "\n")
+		if tracer() != nil {
+			tracer().Error("load settings", "file", name, "error", err)
+		}
+	}
+})
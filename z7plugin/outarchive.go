@@ -0,0 +1,442 @@
+//go:build windows
+
+package z7plugin
+
+import (
+	"context"
+	"io"
+	"iter"
+	"math"
+	"strings"
+	"unsafe"
+
+	"github.com/lxn/win"
+	"github.com/pg9182/7zplugin/winext"
+	"github.com/pg9182/7zplugin/z7"
+)
+
+// CPP/7zip/Archive/IArchive.h
+
+const (
+	vtblIArchiveUpdateCallback_GetUpdateItemInfo  = 5
+	vtblIArchiveUpdateCallback_GetProperty        = 6
+	vtblIArchiveUpdateCallback_GetStream          = 7
+	vtblIArchiveUpdateCallback_SetOperationResult = 8
+	vtblIArchiveUpdateCallback2_GetVolumeStream   = 10
+)
+
+// outArchive implements IOutArchive for an OutArchive.
+type outArchive struct {
+	arc *CArcInfo
+	in  *inArchive // the object the IOutArchive belongs to, if any
+}
+
+// outArchiveObject is implemented by the objects with an IOutArchive interface.
+type outArchiveObject interface {
+	outArchive() *outArchive
+}
+
+func (o *outArchive) outArchive() *outArchive {
+	return o
+}
+
+var vtblOutArchive = newComVtbl([]win.IID{z7.IID_IOutArchive},
+	comMethod("IOutArchive.UpdateItems", func(this uintptr, outStream uintptr, numItems uint32, updateCallback uintptr) uintptr {
+		return uintptr(comImpl[outArchiveObject](this).outArchive().UpdateItems(comPtr(outStream), numItems, comPtr(updateCallback)))
+	}),
+	comMethod("IOutArchive.GetFileTimeType", func(this uintptr, type_ *uint32) uintptr {
+		return uintptr(comImpl[outArchiveObject](this).outArchive().GetFileTimeType(type_))
+	}),
+)
+
+// newOutArchive creates a new IOutArchive object for the format.
+func newOutArchive(arc *CArcInfo) *comObject {
+	return newComObject(&outArchive{arc: arc}, vtblOutArchive)
+}
+
+func (o *outArchive) UpdateItems(outStream comPtr, numItems uint32, updateCallback comPtr) winext.HRESULT {
+	u := &updater{p: updateCallback, arc: o.arc}
+	if o.in != nil {
+		var end func()
+		u.ctx, end = o.in.begin()
+		u.abort = o.in.abort
+		defer end()
+		if o.in.stream != nil {
+			u.in = o.in.h
+		}
+	} else {
+		var cancel context.CancelFunc
+		u.ctx, cancel = context.WithCancel(context.Background())
+		u.abort = cancel
+		defer cancel()
+	}
+	u.prog = newHostProgress(updateCallback, false, u.abort)
+	if o.arc.Flags&z7.NArchive_NArcInfoFlags_kNtSecure != 0 {
+		if p := updateCallback.QueryInterface(z7.IID_IArchiveGetRawProps); p != 0 {
+			defer p.Release()
+			u.raw = hostGetRawProps{p}
+		}
+	}
+
+	items := make([]UpdateItem, numItems)
+	var total uint64
+	for i := range items {
+		it, err := u.item(uint32(i))
+		if err != nil {
+			return errorHRESULT(err)
+		}
+		if !it.Props.IsDir {
+			total += it.Props.Size
+		}
+		items[i] = it
+	}
+	if err := u.prog.SetTotal(0, total); err != nil {
+		return errorHRESULT(err)
+	}
+
+	var w io.Writer = hostSequentialOutStream{outStream, u.abort}
+	if s := outStream.QueryInterface(z7.IID_IOutStream); s != 0 {
+		defer s.Release()
+		w = hostOutStream{s, u.abort}
+	}
+	var err error
+	h := o.arc.CreateOutArchive()
+	if hv, ok := h.(OutArchiveVolumes); ok {
+		vol := hostOutVolumes{abort: u.abort}
+		if vol.p = updateCallback.QueryInterface(z7.IID_IArchiveUpdateCallback2); vol.p != 0 {
+			defer vol.p.Release()
+		}
+		err = hv.UpdateVolumes(u.ctx, w, vol, u.seq(items))
+	} else {
+		err = h.Update(u.ctx, w, u.seq(items))
+	}
+	if u.err != nil {
+		return errorHRESULT(u.err)
+	}
+	if err := u.ctx.Err(); err != nil {
+		return errorHRESULT(err)
+	}
+	return errorHRESULT(err)
+}
+
+func (o *outArchive) GetFileTimeType(type_ *uint32) winext.HRESULT {
+	t := o.arc.TimePrec().fileTimeType()
+	if t == z7.NFileTimeType_kNotDefined {
+		t = z7.NFileTimeType_kWindows
+	}
+	*type_ = uint32(t)
+	return win.S_OK
+}
+
+// updater reads items from an IArchiveUpdateCallback.
+type updater struct {
+	p         comPtr
+	ctx       context.Context
+	abort     func() // cancels ctx
+	arc       *CArcInfo
+	in        InArchive       // the open archive, if any
+	raw       hostGetRawProps // for kpidNtSecure, if supported
+	prog      *hostProgress
+	completed uint64
+	err       error // the first error from the host
+}
+
+// seq iterates over items, stopping if the operation is cancelled.
+func (u *updater) seq(items []UpdateItem) iter.Seq[UpdateItem] {
+	return func(yield func(UpdateItem) bool) {
+		for _, it := range items {
+			if u.err != nil {
+				return
+			}
+			if err := u.prog.setCompleted(0, u.completed); err != nil {
+				u.err = err
+				return
+			}
+			if !yield(it) {
+				return
+			}
+		}
+	}
+}
+
+func (u *updater) item(index uint32) (UpdateItem, error) {
+	var (
+		newData        int32
+		newProps       int32
+		indexInArchive uint32
+	)
+	if hr := u.p.call(vtblIArchiveUpdateCallback_GetUpdateItemInfo, uintptr(index), uintptr(unsafe.Pointer(&newData)), uintptr(unsafe.Pointer(&newProps)), uintptr(unsafe.Pointer(&indexInArchive))); hr != win.S_OK {
+		return UpdateItem{}, hostError(hr, u.abort)
+	}
+	it := UpdateItem{
+		Index:       int(index),
+		FromArchive: -1,
+		NewData:     newData != 0,
+	}
+	var arcItem Item
+	if indexInArchive == math.MaxUint32 {
+		if !it.NewData {
+			return it, HRESULT(win.E_INVALIDARG) // no data for a new item
+		}
+	} else {
+		if u.in == nil || int(indexInArchive) >= u.in.NumItems() {
+			return it, HRESULT(win.E_INVALIDARG)
+		}
+		it.FromArchive = int(indexInArchive)
+
+		var err error
+		if arcItem, err = u.in.Item(it.FromArchive); err != nil {
+			return it, err
+		}
+	}
+	if newProps != 0 || it.FromArchive == -1 {
+		p, err := u.props(index)
+		if err != nil {
+			return it, err
+		}
+		it.Props = p
+	} else {
+		it.Props = arcItem
+	}
+	if it.FromArchive != -1 && !it.NewData {
+		it.Props.Size = arcItem.Size
+	}
+	it.Existing = arcItem
+	it.open = func() (io.ReadCloser, error) {
+		return u.open(it)
+	}
+	it.openRaw = func() (io.ReadCloser, any, error) {
+		return u.openRaw(it)
+	}
+	return it, nil
+}
+
+// props reads the new properties of an item.
+func (u *updater) props(index uint32) (Item, error) {
+	var it Item
+	for _, propID := range []z7.PROPID{
+		z7.PROPID_kpidPath,
+		z7.PROPID_kpidIsDir,
+		z7.PROPID_kpidSize,
+		z7.PROPID_kpidAttrib,
+		z7.PROPID_kpidCTime,
+		z7.PROPID_kpidATime,
+		z7.PROPID_kpidMTime,
+		z7.PROPID_kpidPosixAttrib,
+		z7.PROPID_kpidSymLink,
+		z7.PROPID_kpidHardLink,
+		z7.PROPID_kpidIsAltStream,
+	} {
+		x, err := u.prop(index, propID)
+		if err != nil {
+			return it, err
+		}
+		switch x := x.(type) {
+		case string:
+			switch propID {
+			case z7.PROPID_kpidPath:
+				it.Path = x
+			case z7.PROPID_kpidSymLink:
+				it.SymLink = x
+			case z7.PROPID_kpidHardLink:
+				it.HardLink = x
+			}
+		case bool:
+			switch propID {
+			case z7.PROPID_kpidIsDir:
+				it.IsDir = x
+			case z7.PROPID_kpidIsAltStream:
+				it.IsAltStream = x
+			}
+		case uint32:
+			switch propID {
+			case z7.PROPID_kpidAttrib:
+				it.Attrib = x
+				if it.Mode == 0 && x&z7.FILE_ATTRIBUTE_UNIX_EXTENSION != 0 {
+					it.Mode = fileMode(x >> 16)
+				}
+			case z7.PROPID_kpidPosixAttrib:
+				it.Mode = fileMode(x)
+			}
+		case uint64:
+			if propID == z7.PROPID_kpidSize {
+				it.Size = x
+			}
+		case propTime:
+			switch propID {
+			case z7.PROPID_kpidCTime:
+				it.CTime = x.t
+			case z7.PROPID_kpidATime:
+				it.ATime = x.t
+			case z7.PROPID_kpidMTime:
+				it.MTime = x.t
+			}
+			if x.prec != TimePrecUnknown {
+				it.TimePrec = x.prec
+			}
+		}
+	}
+	if u.raw.p != 0 {
+		b, err := u.raw.RawProperty(index, z7.PROPID_kpidNtSecure)
+		if err != nil {
+			return it, err
+		}
+		it.NtSecure = b
+	}
+	return it, nil
+}
+
+// prop gets a property from the update callback, returning it as the type used
+// by setProp.
+func (u *updater) prop(index uint32, propID z7.PROPID) (any, error) {
+	var value winext.PROPVARIANT
+	if hr := u.p.call(vtblIArchiveUpdateCallback_GetProperty, uintptr(index), uintptr(propID), uintptr(unsafe.Pointer(&value))); hr != win.S_OK {
+		return nil, hostError(hr, u.abort)
+	}
+	return getProp(&value, propID), nil
+}
+
+// open opens the contents of an item.
+func (u *updater) open(it UpdateItem) (io.ReadCloser, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+	if it.Props.IsDir {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	if !it.NewData {
+		return u.openArchive(it.FromArchive)
+	}
+
+	var stream uintptr
+	switch hr := u.p.call(vtblIArchiveUpdateCallback_GetStream, uintptr(it.Index), uintptr(unsafe.Pointer(&stream))); hr {
+	case win.S_OK:
+	case win.S_FALSE:
+		if hr := u.p.call(vtblIArchiveUpdateCallback_SetOperationResult, uintptr(z7.NArchive_NUpdate_NOperationResult_kOK)); hr != win.S_OK {
+			u.err = hostError(hr, u.abort)
+			return nil, u.err
+		}
+		return nil, ErrUnavailable
+	default:
+		u.err = hostError(hr, u.abort)
+		return nil, u.err
+	}
+	s := &hostSequentialInStream{p: comPtr(stream), abort: u.abort}
+	return &updateReader{u: u, r: s, close: func() error {
+		s.Release()
+		if hr := u.p.call(vtblIArchiveUpdateCallback_SetOperationResult, uintptr(z7.NArchive_NUpdate_NOperationResult_kOK)); hr != win.S_OK {
+			u.err = hostError(hr, u.abort)
+			return u.err
+		}
+		return nil
+	}}, nil
+}
+
+// openArchive opens the contents of an item from the existing archive.
+func (u *updater) openArchive(index int) (io.ReadCloser, error) {
+	if g, ok := u.in.(InArchiveGetStream); ok {
+		r, size, err := g.GetStream(index)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			return &updateReader{u: u, r: io.NewSectionReader(r, 0, size)}, nil
+		}
+	}
+	r := newExtractReader(u.ctx, u.in, index)
+	return &updateReader{u: u, r: r, close: r.Close}, nil
+}
+
+// openRaw opens the packed contents of an item from the existing archive.
+func (u *updater) openRaw(it UpdateItem) (io.ReadCloser, any, error) {
+	if u.err != nil {
+		return nil, nil, u.err
+	}
+	if it.NewData || it.FromArchive == -1 {
+		return nil, nil, ErrNoRaw
+	}
+	r, info, err := rawSection(u.in, it.FromArchive)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &updateReader{u: u, r: r}, info, nil
+}
+
+// updateReader reports progress while reading the contents of an item.
+type updateReader struct {
+	u     *updater
+	r     io.Reader
+	close func() error
+}
+
+func (r *updateReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.u.completed += uint64(n)
+	if n != 0 {
+		if err := r.u.prog.SetCompleted(0, r.u.completed); err != nil {
+			r.u.err = err
+			return n, err
+		}
+	}
+	return n, err
+}
+
+func (r *updateReader) Close() error {
+	if r.close == nil {
+		return nil
+	}
+	close := r.close
+	r.close = nil
+	return close()
+}
+
+// hostOutStream wraps an IOutStream from the host as an io.WriteSeeker.
+type hostOutStream struct {
+	p     comPtr
+	abort func() // called if the host returns E_ABORT
+}
+
+func (s hostOutStream) Write(b []byte) (int, error) {
+	return hostSequentialOutStream(s).Write(b)
+}
+
+func (s hostOutStream) Seek(offset int64, whence int) (int64, error) {
+	var pos uint64
+	if hr := s.p.callUint64(vtblIOutStream_Seek, uint64(offset), uintptr(whence), uintptr(unsafe.Pointer(&pos))); hr != win.S_OK {
+		return 0, hostError(hr, s.abort)
+	}
+	return int64(pos), nil
+}
+
+// hostOutVolumes wraps an IArchiveUpdateCallback2 from the host as OutVolumes.
+type hostOutVolumes struct {
+	p     comPtr // zero if not supported
+	abort func() // called if the host returns E_ABORT
+}
+
+func (v hostOutVolumes) CreateVolume(index int) (io.WriteCloser, error) {
+	if v.p == 0 || index < 0 || uint64(index) > math.MaxUint32 {
+		return nil, E_NOTIMPL
+	}
+	var stream uintptr
+	if hr := v.p.call(vtblIArchiveUpdateCallback2_GetVolumeStream, uintptr(index), uintptr(unsafe.Pointer(&stream))); hr != win.S_OK {
+		return nil, hostError(hr, v.abort)
+	}
+	if stream == 0 {
+		return nil, E_FAIL
+	}
+	return &hostVolumeStream{hostSequentialOutStream{comPtr(stream), v.abort}}, nil
+}
+
+// hostVolumeStream is a volume created by hostOutVolumes.
+type hostVolumeStream struct {
+	hostSequentialOutStream
+}
+
+func (s *hostVolumeStream) Close() error {
+	if s.p != 0 {
+		s.p.Release()
+		s.p = 0
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+//go:build windows
+
+package z7plugin
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/lxn/win"
+	"github.com/pg9182/7zplugin/winext"
+)
+
+// CPP/7zip/IProgress.h
+// CPP/7zip/Archive/IArchive.h (IArchiveOpenCallback)
+
+// progressInterval is the minimum time between SetCompleted calls to the host.
+const progressInterval = 50 * time.Millisecond
+
+// hostProgress wraps an IProgress or IArchiveOpenCallback from the host as a
+// Progress. Both interfaces have SetTotal and SetCompleted at the same vtable
+// indices, but IArchiveOpenCallback takes pointers to the number of files and
+// bytes instead of the number of bytes.
+type hostProgress struct {
+	p     comPtr
+	open  bool   // IArchiveOpenCallback
+	abort func() // called if the host returns E_ABORT
+	last  time.Time
+	err   error // the first error from the host
+}
+
+// newHostProgress wraps p, which is an IArchiveOpenCallback if open is true.
+// If p is zero, the methods do nothing.
+func newHostProgress(p comPtr, open bool, abort func()) *hostProgress {
+	return &hostProgress{p: p, open: open, abort: abort}
+}
+
+func (p *hostProgress) SetTotal(files, bytes uint64) error {
+	if p.err != nil || p.p == 0 {
+		return p.err
+	}
+	if p.open {
+		return p.result(p.p.call(vtblIProgress_SetTotal, uintptr(unsafe.Pointer(optUint64(files))), uintptr(unsafe.Pointer(optUint64(bytes)))))
+	}
+	return p.result(p.p.callUint64(vtblIProgress_SetTotal, bytes))
+}
+
+func (p *hostProgress) SetCompleted(files, bytes uint64) error {
+	if p.err != nil || p.p == 0 {
+		return p.err
+	}
+	if now := time.Now(); now.Sub(p.last) >= progressInterval {
+		p.last = now
+		return p.setCompleted(files, bytes)
+	}
+	return nil
+}
+
+// setCompleted is like SetCompleted, but isn't rate-limited.
+func (p *hostProgress) setCompleted(files, bytes uint64) error {
+	if p.err != nil || p.p == 0 {
+		return p.err
+	}
+	if p.open {
+		return p.result(p.p.call(vtblIProgress_SetCompleted, uintptr(unsafe.Pointer(optUint64(files))), uintptr(unsafe.Pointer(optUint64(bytes)))))
+	}
+	return p.result(p.p.call(vtblIProgress_SetCompleted, uintptr(unsafe.Pointer(&bytes))))
+}
+
+func (p *hostProgress) result(hr winext.HRESULT) error {
+	if hr != win.S_OK {
+		p.err = hostError(hr, p.abort)
+	}
+	return p.err
+}
+
+// optUint64 returns a pointer to x, or nil if it is zero.
+func optUint64(x uint64) *uint64 {
+	if x == 0 {
+		return nil
+	}
+	return &x
+}
@@ -0,0 +1,120 @@
+//go:build windows
+
+package z7plugin
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lxn/win"
+	"github.com/pg9182/7zplugin/winext"
+	"github.com/pg9182/7zplugin/z7"
+	"golang.org/x/sys/windows"
+)
+
+// CPP/7zip/PropID.h
+
+// propVarTypes contains the types of properties which may be returned by
+// GetPropertyInfo and GetArchivePropertyInfo.
+var propVarTypes = map[z7.PROPID]win.VARTYPE{
+	z7.PROPID_kpidPath:        win.VT_BSTR,
+	z7.PROPID_kpidName:        win.VT_BSTR,
+	z7.PROPID_kpidExtension:   win.VT_BSTR,
+	z7.PROPID_kpidIsDir:       win.VT_BOOL,
+	z7.PROPID_kpidSize:        win.VT_UI8,
+	z7.PROPID_kpidPackSize:    win.VT_UI8,
+	z7.PROPID_kpidAttrib:      win.VT_UI4,
+	z7.PROPID_kpidCTime:       win.VT_FILETIME,
+	z7.PROPID_kpidATime:       win.VT_FILETIME,
+	z7.PROPID_kpidMTime:       win.VT_FILETIME,
+	z7.PROPID_kpidSolid:       win.VT_BOOL,
+	z7.PROPID_kpidEncrypted:   win.VT_BOOL,
+	z7.PROPID_kpidCRC:         win.VT_UI4,
+	z7.PROPID_kpidType:        win.VT_BSTR,
+	z7.PROPID_kpidMethod:      win.VT_BSTR,
+	z7.PROPID_kpidHostOS:      win.VT_BSTR,
+	z7.PROPID_kpidComment:     win.VT_BSTR,
+	z7.PROPID_kpidOffset:      win.VT_UI8,
+	z7.PROPID_kpidNumBlocks:   win.VT_UI4,
+	z7.PROPID_kpidNumVolumes:  win.VT_UI4,
+	z7.PROPID_kpidPhySize:     win.VT_UI8,
+	z7.PROPID_kpidHeadersSize: win.VT_UI8,
+	z7.PROPID_kpidNumSubDirs:  win.VT_UI4,
+	z7.PROPID_kpidNumSubFiles: win.VT_UI4,
+	z7.PROPID_kpidUnpackSize:  win.VT_UI8,
+	z7.PROPID_kpidIsAltStream: win.VT_BOOL,
+	z7.PROPID_kpidIsTree:      win.VT_BOOL,
+	z7.PROPID_kpidPosixAttrib: win.VT_UI4,
+	z7.PROPID_kpidSymLink:     win.VT_BSTR,
+	z7.PROPID_kpidHardLink:    win.VT_BSTR,
+	z7.PROPID_kpidTimeType:    win.VT_UI4,
+}
+
+// propTime is a timestamp with a precision.
+type propTime struct {
+	t    time.Time
+	prec TimePrec
+}
+
+// setProp sets a PROPVARIANT to a Go value.
+func setProp(value *winext.PROPVARIANT, propID z7.PROPID, x any) winext.HRESULT {
+	value.Vt = win.VT_EMPTY
+	switch x := x.(type) {
+	case nil:
+	case string:
+		if propID == z7.PROPID_kpidPath || propID == z7.PROPID_kpidHardLink {
+			x = filepath.FromSlash(x)
+		}
+		value.SetBSTR(win.SysAllocString(x))
+	case []byte:
+		value.SetBSTR(winext.SysAllocStringByteLen(x))
+	case bool:
+		if x {
+			value.SetBool(win.VARIANT_TRUE)
+		} else {
+			value.SetBool(win.VARIANT_FALSE)
+		}
+	case int32:
+		value.SetLong(x)
+	case uint32:
+		value.SetULong(x)
+	case int64:
+		winext.PropVariantSetInt64(value, x)
+	case uint64:
+		winext.PropVariantSetUInt64(value, x)
+	case time.Time:
+		return setProp(value, propID, propTime{x, TimePrecUnknown})
+	case propTime:
+		ticks, ns := x.prec.split(x.t)
+		winext.PropVariantSetFiletime(value, windows.NsecToFiletime(ticks*100), uint16(x.prec), ns)
+	default:
+		return win.E_INVALIDARG
+	}
+	return win.S_OK
+}
+
+// getProp gets the Go value of a PROPVARIANT, as the type used by setProp, then
+// clears it.
+func getProp(value *winext.PROPVARIANT, propID z7.PROPID) any {
+	defer winext.PropVariantClear(value)
+	switch value.Vt {
+	case win.VT_BSTR:
+		x := win.BSTRToString(value.MustBSTR())
+		if propID == z7.PROPID_kpidPath || propID == z7.PROPID_kpidHardLink {
+			x = strings.ReplaceAll(x, string(filepath.Separator), "/")
+		}
+		return x
+	case win.VT_BOOL:
+		return value.MustBool() != win.VARIANT_FALSE
+	case win.VT_UI4:
+		return value.MustULong()
+	case win.VT_UI8:
+		return winext.PropVariantUInt64(value)
+	case win.VT_FILETIME:
+		ft, prec, ns := winext.PropVariantFiletime(value)
+		return propTime{time.Unix(0, ft.Nanoseconds()+int64(ns%100)), TimePrec(prec)}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,48 @@
+package z7plugin
+
+import (
+	"github.com/pg9182/7zplugin/z7"
+)
+
+// CPP/7zip/Common/RegisterArc.h
+
+type CArcInfo struct {
+	Flags            z7.NArchive_NArcInfoFlags
+	CLSID            z7.GUID
+	SignatureOffset  uint16
+	Signature        string
+	Name             string
+	Ext              string
+	AddExt           string
+	TimeFlags        uint32
+	TimePrecs        []TimePrec // supported timestamp precisions (the first is the default), added to TimeFlags
+	CreateInArchive  func() InArchive
+	CreateOutArchive func() OutArchive
+	IsArc            func(b []byte) z7.NArchive_k_IsArc_Res
+	Settings         any // pointer to the settings for the format (see LoadSettings)
+}
+
+func (arcInfo CArcInfo) IsMultiSignature() bool {
+	return arcInfo.Flags&z7.NArchive_NArcInfoFlags_kMultiSignature != 0
+}
+
+// TimePrec gets the default timestamp precision.
+func (arcInfo CArcInfo) TimePrec() TimePrec {
+	if len(arcInfo.TimePrecs) != 0 {
+		return arcInfo.TimePrecs[0]
+	}
+	return TimePrecUnknown
+}
+
+var _Arcs []*CArcInfo
+
+// RegisterArc registers an archive format. It should be called from an init
+// function.
+func RegisterArc(arcInfo *CArcInfo) {
+	_Arcs = append(_Arcs, arcInfo)
+}
+
+// Arcs gets the registered archive formats in the order they were registered.
+func Arcs() []*CArcInfo {
+	return _Arcs
+}
@@ -0,0 +1,68 @@
+package z7plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Settings are loaded from a JSON file next to the DLL with the extension
+// replaced with ".settings.json" (e.g., go7zPlugin64.settings.json), or the
+// file named by Z7PLUGIN_SETTINGS. It contains an object with the settings for
+// each format by name, which are unmarshaled into CArcInfo.Settings before the
+// first archive is created. For example:
+//
+//	{
+//	    "VPK0203": {
+//	        "chunkPaths": ["D:\\Titanfall2\\vpk"]
+//	    }
+//	}
+//
+// Since 7-Zip doesn't have a way to show errors from plugins when they are
+// loaded, errors loading the settings are written with OutputDebugString (which
+// can be viewed with DebugView) and to the trace log.
+
+// LoadSettings unmarshals the settings in r into the Settings of the registered
+// formats. Settings for formats which aren't registered are ignored. If the
+// settings for some formats are invalid, the others are still applied, and the
+// errors are joined.
+func LoadSettings(r io.Reader) error {
+	var m map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return fmt.Errorf("parse settings: %w", err)
+	}
+	var errs []error
+	for _, arc := range _Arcs {
+		raw, ok := m[arc.Name]
+		if !ok {
+			continue
+		}
+		if err := loadArcSettings(arc, raw); err != nil {
+			errs = append(errs, fmt.Errorf("parse settings for format %s: %w", arc.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// loadArcSettings unmarshals raw into the settings for arc. The settings are
+// only changed if raw is valid.
+func loadArcSettings(arc *CArcInfo, raw json.RawMessage) error {
+	v := reflect.ValueOf(arc.Settings)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("format does not have any settings")
+	}
+	decode := func(x any) error {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		return dec.Decode(x)
+	}
+	// decode it into an empty value first since decoding stops at the first
+	// error, and the existing settings can't be copied deeply
+	if err := decode(reflect.New(v.Elem().Type()).Interface()); err != nil {
+		return err
+	}
+	return decode(arc.Settings)
+}
@@ -0,0 +1,63 @@
+package z7plugin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadSettings(t *testing.T) {
+	defer func(arcs []*CArcInfo) { _Arcs = arcs }(_Arcs)
+
+	type settings struct {
+		Paths []string `json:"paths"`
+		N     int      `json:"n"`
+	}
+	var (
+		a = settings{N: 1}
+		b = settings{N: 2, Paths: []string{"b"}}
+		c = settings{N: 3}
+	)
+	_Arcs = []*CArcInfo{
+		{Name: "A", Settings: &a},
+		{Name: "B", Settings: &b},
+		{Name: "C", Settings: &c},
+		{Name: "None"},
+	}
+	err := LoadSettings(strings.NewReader(`{
+		"A": {"paths": ["x", "y"]},
+		"B": {"paths": ["z"], "n": "invalid"},
+		"C": {"unknown": true},
+		"None": {},
+		"Unregistered": {"n": 5}
+	}`))
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	for _, x := range []string{"format B", "format C", "format None"} {
+		if !strings.Contains(err.Error(), x) {
+			t.Errorf("expected error for %s, got: %v", x, err)
+		}
+	}
+	if strings.Contains(err.Error(), "format A") {
+		t.Errorf("unexpected error for format A: %v", err)
+	}
+	if a.N != 1 || len(a.Paths) != 2 || a.Paths[0] != "x" || a.Paths[1] != "y" {
+		t.Errorf("valid settings not applied: %+v", a)
+	}
+	if b.N != 2 || len(b.Paths) != 1 || b.Paths[0] != "b" {
+		t.Errorf("invalid settings partially applied: %+v", b)
+	}
+	if c.N != 3 {
+		t.Errorf("invalid settings partially applied: %+v", c)
+	}
+
+	if err := LoadSettings(strings.NewReader(`{"A": {"n": 4}}`)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if a.N != 4 || len(a.Paths) != 2 {
+		t.Errorf("settings not merged: %+v", a)
+	}
+
+	if err := LoadSettings(strings.NewReader(`[`)); err == nil {
+		t.Errorf("expected error for invalid json")
+	}
+}
@@ -0,0 +1,300 @@
+//go:build windows
+
+package z7plugin
+
+import (
+	"io"
+	"math"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/lxn/win"
+	"github.com/pg9182/7zplugin/winext"
+	"github.com/pg9182/7zplugin/z7"
+	"golang.org/x/sys/windows"
+)
+
+// CPP/7zip/IStream.h
+
+const (
+	vtblISequentialInStream_Read   = 3
+	vtblIInStream_Seek             = 4
+	vtblISequentialOutStream_Write = 3
+	vtblIOutStream_Seek            = 4
+)
+
+// hostInStream wraps an IInStream from the host as an io.ReaderAt.
+type hostInStream struct {
+	mu    sync.Mutex
+	p     comPtr
+	pos   int64
+	abort func() // called if the host returns E_ABORT
+}
+
+// newHostInStream wraps p, adding a reference to it.
+func newHostInStream(p comPtr) *hostInStream {
+	p.AddRef()
+	return &hostInStream{p: p, pos: -1}
+}
+
+// Release releases the reference to the stream.
+func (s *hostInStream) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.p != 0 {
+		s.p.Release()
+		s.p = 0
+	}
+}
+
+func (s *hostInStream) seek(offset int64, whence int) (int64, error) {
+	var pos uint64
+	if hr := s.p.callUint64(vtblIInStream_Seek, uint64(offset), uintptr(whence), uintptr(unsafe.Pointer(&pos))); hr != win.S_OK {
+		s.pos = -1
+		return 0, hostError(hr, s.abort)
+	}
+	s.pos = int64(pos)
+	return s.pos, nil
+}
+
+// Size gets the size of the stream.
+func (s *hostInStream) Size() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seek(0, io.SeekEnd)
+}
+
+func (s *hostInStream) ReadAt(b []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pos != off {
+		if _, err := s.seek(off, io.SeekStart); err != nil {
+			return 0, err
+		}
+	}
+	var n int
+	for n < len(b) {
+		var processed uint32
+		size := uint32(min(len(b)-n, math.MaxInt32))
+		hr := s.p.call(vtblISequentialInStream_Read, uintptr(unsafe.Pointer(&b[n])), uintptr(size), uintptr(unsafe.Pointer(&processed)))
+		runtime.KeepAlive(b)
+		n += int(processed)
+		s.pos += int64(processed)
+		if hr != win.S_OK {
+			s.pos = -1
+			return n, hostError(hr, s.abort)
+		}
+		if processed == 0 {
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+// hostSequentialInStream wraps an ISequentialInStream from the host as an
+// io.Reader.
+type hostSequentialInStream struct {
+	p     comPtr
+	n     int64  // bytes read
+	abort func() // called if the host returns E_ABORT
+}
+
+// newHostSequentialInStream wraps p, adding a reference to it.
+func newHostSequentialInStream(p comPtr, abort func()) *hostSequentialInStream {
+	p.AddRef()
+	return &hostSequentialInStream{p: p, abort: abort}
+}
+
+// Release releases the reference to the stream.
+func (s *hostSequentialInStream) Release() {
+	if s.p != 0 {
+		s.p.Release()
+		s.p = 0
+	}
+}
+
+func (s *hostSequentialInStream) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	var processed uint32
+	size := uint32(min(len(b), math.MaxInt32))
+	hr := s.p.call(vtblISequentialInStream_Read, uintptr(unsafe.Pointer(&b[0])), uintptr(size), uintptr(unsafe.Pointer(&processed)))
+	runtime.KeepAlive(b)
+	s.n += int64(processed)
+	if hr != win.S_OK {
+		return int(processed), hostError(hr, s.abort)
+	}
+	if processed == 0 {
+		return 0, io.EOF
+	}
+	return int(processed), nil
+}
+
+// hostSequentialOutStream wraps an ISequentialOutStream from the host as an
+// io.Writer.
+type hostSequentialOutStream struct {
+	p     comPtr
+	abort func() // called if the host returns E_ABORT
+}
+
+func (s hostSequentialOutStream) Write(b []byte) (int, error) {
+	var n int
+	for n < len(b) {
+		var processed uint32
+		size := uint32(min(len(b)-n, math.MaxInt32))
+		hr := s.p.call(vtblISequentialOutStream_Write, uintptr(unsafe.Pointer(&b[n])), uintptr(size), uintptr(unsafe.Pointer(&processed)))
+		runtime.KeepAlive(b)
+		n += int(processed)
+		if hr != win.S_OK {
+			return n, hostError(hr, s.abort)
+		}
+		if processed == 0 {
+			return n, io.ErrShortWrite
+		}
+	}
+	return n, nil
+}
+
+// InStream implements IInStream and IStreamGetSize for an io.ReaderAt or an
+// io.ReadSeeker. It can be used wherever 7-Zip expects a stream.
+//
+// Like any IInStream, it has a single position shared by Seek and Read, so a
+// stream should only be used by one consumer at a time. The calls themselves
+// are serialized, so r is never used concurrently.
+type InStream struct {
+	obj  *comObject
+	mu   sync.Mutex
+	r    io.ReaderAt
+	size int64
+	pos  int64
+}
+
+var vtblInStream = newComVtbl([]win.IID{z7.IID_ISequentialInStream, z7.IID_IInStream},
+	comMethod("ISequentialInStream.Read", func(this uintptr, data *byte, size uint32, processedSize *uint32) uintptr {
+		return uintptr(comImpl[*InStream](this).read(unsafe.Slice(data, size), processedSize))
+	}),
+	newSeekCallback("IInStream.Seek", func(this uintptr, offset int64, seekOrigin uint32, newPosition *uint64) uintptr {
+		return uintptr(comImpl[*InStream](this).seek(offset, seekOrigin, newPosition))
+	}),
+)
+
+var vtblInStreamGetSize = newComVtbl([]win.IID{z7.IID_IStreamGetSize},
+	comMethod("IStreamGetSize.GetSize", func(this uintptr, size *uint64) uintptr {
+		return uintptr(comImpl[*InStream](this).getSize(size))
+	}),
+)
+
+// NewInStream creates a stream reading size bytes from r. The returned stream
+// has one reference, which must be released with Release.
+func NewInStream(r io.ReaderAt, size int64) *InStream {
+	s := &InStream{r: r, size: size}
+	s.obj = newComObject(s, vtblInStream, vtblInStreamGetSize)
+	s.obj.ref()
+	return s
+}
+
+// NewInStreamSeeker creates a stream reading from r, which will be seeked as
+// required. The size is determined by seeking to the end. As with NewInStream,
+// the stream must be released with Release.
+func NewInStreamSeeker(r io.ReadSeeker) (*InStream, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	return NewInStream(&readSeekerAt{r: r}, size), nil
+}
+
+// IInStream adds a reference to the stream, returning a pointer to its IInStream
+// interface. The reference must be released by the recipient.
+func (s *InStream) IInStream() uintptr {
+	return s.obj.ref()
+}
+
+// Release releases the reference returned by NewInStream. The stream is freed
+// once the references returned by IInStream are also released.
+func (s *InStream) Release() {
+	s.obj.release()
+}
+
+// Size returns the size of the stream.
+func (s *InStream) Size() int64 {
+	return s.size
+}
+
+func (s *InStream) read(data []byte, processedSize *uint32) winext.HRESULT {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if processedSize != nil {
+		*processedSize = 0
+	}
+	if s.pos >= s.size {
+		return win.S_OK
+	}
+	if rem := s.size - s.pos; int64(len(data)) > rem {
+		data = data[:rem]
+	}
+	n, err := s.r.ReadAt(data, s.pos)
+	s.pos += int64(n)
+	if processedSize != nil {
+		*processedSize = uint32(n)
+	}
+	if err != nil && (err != io.EOF || n != len(data)) {
+		return errorHRESULT(err)
+	}
+	return win.S_OK
+}
+
+func (s *InStream) seek(offset int64, seekOrigin uint32, newPosition *uint64) winext.HRESULT {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// CPP/7zip/Common/StreamObjects.cpp CBufInStream::Seek
+	var base int64
+	switch seekOrigin {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		base = s.pos
+	case io.SeekEnd:
+		base = s.size
+	default:
+		return winext.HRESULT(windows.STG_E_INVALIDFUNCTION)
+	}
+	if offset > 0 && base > math.MaxInt64-offset {
+		return win.E_INVALIDARG
+	}
+	if offset += base; offset < 0 {
+		return winext.HRESULT(HRESULT_FROM_WIN32(windows.ERROR_NEGATIVE_SEEK))
+	}
+	s.pos = offset
+	if newPosition != nil {
+		*newPosition = uint64(s.pos)
+	}
+	return win.S_OK
+}
+
+func (s *InStream) getSize(size *uint64) winext.HRESULT {
+	*size = uint64(s.size)
+	return win.S_OK
+}
+
+// readSeekerAt implements io.ReaderAt for an io.ReadSeeker.
+type readSeekerAt struct {
+	mu sync.Mutex
+	r  io.ReadSeeker
+}
+
+func (r *readSeekerAt) ReadAt(b []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.r, b)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,68 @@
+package z7plugin
+
+import (
+	"time"
+
+	"github.com/pg9182/7zplugin/z7"
+)
+
+// TimePrec is the precision of item timestamps.
+type TimePrec uint16
+
+const (
+	TimePrecUnknown TimePrec = z7.K_PropVar_TimePrec_0
+	TimePrecUnix    TimePrec = z7.K_PropVar_TimePrec_Unix  // 1s (unix seconds)
+	TimePrecDOS     TimePrec = z7.K_PropVar_TimePrec_DOS   // 2s (DOS date/time)
+	TimePrec100ns   TimePrec = z7.K_PropVar_TimePrec_100ns // 100ns (windows FILETIME)
+	TimePrec1ns     TimePrec = z7.K_PropVar_TimePrec_1ns   // 1ns
+)
+
+// fileTimeType converts p into a NFileTimeType (i.e., kpidTimeType).
+func (p TimePrec) fileTimeType() z7.NFileTimeType {
+	switch p {
+	case TimePrecUnix:
+		return z7.NFileTimeType_kUnix
+	case TimePrecDOS:
+		return z7.NFileTimeType_kDOS
+	case TimePrec100ns:
+		return z7.NFileTimeType_kWindows
+	case TimePrec1ns:
+		return z7.NFileTimeType_k1ns
+	default:
+		return z7.NFileTimeType_kNotDefined
+	}
+}
+
+// timeFlags encodes precisions for NArchive_NHandlerPropID_kTimeFlags, the
+// first one being the default.
+func timeFlags(prec []TimePrec) uint32 {
+	var x uint32
+	for _, p := range prec {
+		x |= z7.TIME_PREC_TO_ARC_FLAGS_MASK(uint32(p))
+	}
+	if len(prec) != 0 {
+		x |= z7.TIME_PREC_TO_ARC_FLAGS_TIME_DEFAULT(uint32(prec[0]))
+	}
+	return x
+}
+
+// split truncates t to the precision, returning the number of 100ns intervals
+// since the unix epoch, and the remaining nanoseconds (0-99) if the precision
+// is TimePrec1ns. This is how 7-Zip stores timestamps in a PROPVARIANT.
+func (p TimePrec) split(t time.Time) (ticks int64, ns uint16) {
+	n := t.UnixNano()
+	switch p {
+	case TimePrecUnix:
+		n -= floorMod(n, 1e9)
+	case TimePrecDOS:
+		n -= floorMod(n, 2e9)
+	case TimePrec1ns:
+		ns = uint16(floorMod(n, 100))
+	}
+	return (n - floorMod(n, 100)) / 100, ns
+}
+
+// floorMod is like a % b, but the result has the same sign as b.
+func floorMod(a, b int64) int64 {
+	return (a%b + b) % b
+}
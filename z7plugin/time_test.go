@@ -0,0 +1,56 @@
+package z7plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pg9182/7zplugin/z7"
+)
+
+func TestTimePrecSplit(t *testing.T) {
+	var (
+		t1 = time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC) // odd second
+		t2 = time.Date(1969, 12, 31, 23, 59, 58, 987654321, time.UTC)
+	)
+	for _, tc := range []struct {
+		t     time.Time
+		prec  TimePrec
+		ticks int64
+		ns    uint16
+	}{
+		{t1, TimePrecUnknown, t1.UnixNano() / 100, 0},
+		{t1, TimePrecUnix, t1.Unix() * 1e7, 0},
+		{t1, TimePrecDOS, (t1.Unix() - 1) * 1e7, 0},
+		{t1, TimePrec100ns, t1.UnixNano() / 100, 0},
+		{t1, TimePrec1ns, t1.UnixNano() / 100, 89},
+		{t2, TimePrecUnknown, -10123457, 0},
+		{t2, TimePrecUnix, -2e7, 0},
+		{t2, TimePrecDOS, -2e7, 0},
+		{t2, TimePrec100ns, -10123457, 0},
+		{t2, TimePrec1ns, -10123457, 21},
+	} {
+		ticks, ns := tc.prec.split(tc.t)
+		if ticks != tc.ticks || ns != tc.ns {
+			t.Errorf("split(%v, %d) = %d, %d; expected %d, %d", tc.t, tc.prec, ticks, ns, tc.ticks, tc.ns)
+		}
+		if x := time.Unix(0, ticks*100+int64(ns)); x.After(tc.t) {
+			t.Errorf("split(%v, %d) rounded up to %v", tc.t, tc.prec, x)
+		}
+	}
+}
+
+func TestTimeFlags(t *testing.T) {
+	for _, tc := range []struct {
+		prec []TimePrec
+		x    uint32
+	}{
+		{nil, 0},
+		{[]TimePrec{TimePrecUnix}, 1<<z7.K_PropVar_TimePrec_Unix | z7.K_PropVar_TimePrec_Unix<<27},
+		{[]TimePrec{TimePrec100ns, TimePrecDOS}, 1<<z7.K_PropVar_TimePrec_100ns | 1<<z7.K_PropVar_TimePrec_DOS | z7.K_PropVar_TimePrec_100ns<<27},
+		{[]TimePrec{TimePrec1ns}, 1<<z7.K_PropVar_TimePrec_1ns | z7.K_PropVar_TimePrec_1ns<<27},
+	} {
+		if x := timeFlags(tc.prec); x != tc.x {
+			t.Errorf("timeFlags(%v) = %#x, expected %#x", tc.prec, x, tc.x)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+//go:build windows
+
+package z7plugin
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lxn/win"
+	"github.com/pg9182/7zplugin/winext"
+)
+
+// Tracing logs every export, COM method and host COM call to a file. It is
+// enabled by setting Z7PLUGIN_TRACE to the path of the log file, or by creating
+// a file next to the DLL with the extension replaced with ".trace" (e.g.,
+// go7zPlugin64.trace), which the log is appended to.
+
+// tracer gets the trace logger, or nil if tracing is disabled. It is opened
+// when first used so it doesn't depend on the package initialization order.
+var tracer = sync.OnceValue(openTrace)
+
+func openTrace() *slog.Logger {
+	name := os.Getenv("Z7PLUGIN_TRACE")
+	if name == "" {
+		if p, err := modulePath(); err == nil {
+			if t := strings.TrimSuffix(p, filepath.Ext(p)) + ".trace"; fileExists(t) {
+				name = t
+			}
+		}
+	}
+	if name == "" {
+		return nil
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil
+	}
+	l := slog.New(slog.NewTextHandler(f, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	if p, err := modulePath(); err == nil {
+		l.Info("loaded", "module", p, "pid", os.Getpid())
+	}
+	return l
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// exportParams contains the parameter names of the exports.
+var exportParams = map[string][]string{
+	"CreateObject":        {"clsid", "iid", "outObject"},
+	"GetHandlerProperty":  {"propID", "value"},
+	"GetNumberOfFormats":  {"numFormats"},
+	"GetHandlerProperty2": {"formatIndex", "propID", "value"},
+	"GetIsArc":            {"formatIndex", "isArc"},
+	"GetNumberOfMethods":  {"numCodecs"},
+	"GetMethodProperty":   {"codecIndex", "propID", "value"},
+	"CreateDecoder":       {"index", "iid", "outObject"},
+	"CreateEncoder":       {"index", "iid", "outObject"},
+	"GetHashers":          {"hashers"},
+	"SetCodecs":           {"codecs"},
+	"SetLargePageMode":    {},
+	"SetCaseSensitive":    {"caseSensitive"},
+	"GetModuleProp":       {"propID", "value"},
+}
+
+// export sets an export in internal.Archive2, logging calls to it if tracing
+// is enabled.
+func export[T any](name string, p *T, fn T) {
+	*p = traceFunc("export", name, exportParams[name], fn)
+}
+
+// comMethod creates a callback for a COM method, logging calls to it if
+// tracing is enabled.
+func comMethod(name string, fn any) uintptr {
+	return syscall.NewCallback(traceFunc("com", name, nil, fn))
+}
+
+// traceFunc wraps fn, which must return a HRESULT, to log calls to it if
+// tracing is enabled.
+func traceFunc[T any](kind, name string, params []string, fn T) T {
+	if tracer() == nil {
+		return fn
+	}
+	v := reflect.ValueOf(fn)
+	return reflect.MakeFunc(v.Type(), func(args []reflect.Value) []reflect.Value {
+		start := time.Now()
+		ret := v.Call(args)
+		attrs := make([]slog.Attr, 0, len(args)+4)
+		attrs = append(attrs, slog.String(kind, name))
+		for i, a := range args {
+			n := fmt.Sprintf("arg%d", i)
+			if i < len(params) {
+				n = params[i]
+			}
+			attrs = append(attrs, slog.String(n, traceValue(a)))
+		}
+		attrs = append(attrs,
+			slog.String("hr", fmt.Sprintf("0x%08X", ret[0].Convert(reflect.TypeFor[uint32]()).Interface())),
+			slog.Duration("duration", time.Since(start)),
+		)
+		tracer().LogAttrs(context.Background(), slog.LevelDebug, "call", attrs...)
+		return ret
+	}).Interface().(T)
+}
+
+// traceHost logs a call to a host COM method if tracing is enabled.
+func traceHost(p comPtr, method int, start time.Time, hr winext.HRESULT) {
+	tracer().LogAttrs(context.Background(), slog.LevelDebug, "call",
+		slog.String("host", fmt.Sprintf("%#x", uintptr(p))),
+		slog.Int("method", method),
+		slog.String("hr", fmt.Sprintf("0x%08X", uint32(hr))),
+		slog.Duration("duration", time.Since(start)),
+	)
+}
+
+func traceValue(v reflect.Value) string {
+	switch x := v.Interface().(type) {
+	case win.CLSID:
+		return winext.GUIDToString(x)
+	case win.IID:
+		return winext.GUIDToString(x)
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.UnsafePointer:
+		return fmt.Sprintf("%#x", v.Pointer())
+	case reflect.Uintptr:
+		return fmt.Sprintf("%#x", v.Uint())
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
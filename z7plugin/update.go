@@ -0,0 +1,132 @@
+package z7plugin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"strings"
+)
+
+// NewUpdateItem creates an UpdateItem for a new item with the contents read
+// with open, for using an OutArchive outside 7-Zip.
+func NewUpdateItem(index int, props Item, open func() (io.ReadCloser, error)) UpdateItem {
+	return UpdateItem{
+		Index:       index,
+		FromArchive: -1,
+		NewData:     true,
+		Props:       props,
+		open:        open,
+		openRaw: func() (io.ReadCloser, any, error) {
+			return nil, nil, ErrNoRaw
+		},
+	}
+}
+
+// KeepUpdateItem creates an UpdateItem which keeps an item from an open
+// InArchive unchanged, for using an OutArchive outside 7-Zip. The contents are
+// extracted with ctx.
+func KeepUpdateItem(ctx context.Context, index int, in InArchive, fromArchive int) (UpdateItem, error) {
+	if fromArchive < 0 || fromArchive >= in.NumItems() {
+		return UpdateItem{}, E_INVALIDARG
+	}
+	it, err := in.Item(fromArchive)
+	if err != nil {
+		return UpdateItem{}, err
+	}
+	return UpdateItem{
+		Index:       index,
+		FromArchive: fromArchive,
+		Props:       it,
+		Existing:    it,
+		open: func() (io.ReadCloser, error) {
+			if it.IsDir {
+				return io.NopCloser(strings.NewReader("")), nil
+			}
+			return newExtractReader(ctx, in, fromArchive), nil
+		},
+		openRaw: func() (io.ReadCloser, any, error) {
+			r, info, err := rawSection(in, fromArchive)
+			if err != nil {
+				return nil, nil, err
+			}
+			return io.NopCloser(r), info, nil
+		},
+	}, nil
+}
+
+// rawSection gets the packed contents of an item with InArchiveRawCopy,
+// returning ErrNoRaw if they aren't available.
+func rawSection(in InArchive, index int) (*io.SectionReader, any, error) {
+	c, ok := in.(InArchiveRawCopy)
+	if !ok {
+		return nil, nil, ErrNoRaw
+	}
+	r, size, info, err := c.RawStream(index)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r == nil {
+		return nil, nil, ErrNoRaw
+	}
+	return io.NewSectionReader(r, 0, size), info, nil
+}
+
+// extractReader reads the contents of an item extracted by an InArchive. The
+// handler only runs while Read is waiting for data (i.e., never concurrently
+// with the caller), since the InArchive and the host streams it uses must not
+// be used by multiple threads at once.
+type extractReader struct {
+	next func() ([]byte, error, bool)
+	stop func()
+	buf  []byte
+	err  error
+}
+
+// errExtractClosed is returned to the handler if the reader is closed before
+// the item has been fully extracted.
+var errExtractClosed = errors.New("extract reader closed")
+
+func newExtractReader(ctx context.Context, in InArchive, index int) *extractReader {
+	next, stop := iter.Pull2(func(yield func([]byte, error) bool) {
+		err := in.Extract(ctx, index, writerFunc(func(b []byte) (int, error) {
+			if len(b) != 0 && !yield(b, nil) {
+				return 0, errExtractClosed
+			}
+			return len(b), nil
+		}))
+		if err == nil {
+			err = io.EOF
+		}
+		yield(nil, err)
+	})
+	return &extractReader{next: next, stop: stop}
+}
+
+func (r *extractReader) Read(b []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		var ok bool
+		if r.buf, r.err, ok = r.next(); !ok {
+			r.err = io.ErrClosedPipe
+		}
+	}
+	n := copy(b, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *extractReader) Close() error {
+	r.stop()
+	r.buf, r.err = nil, io.ErrClosedPipe
+	return nil
+}
+
+// writerFunc implements io.Writer with a function.
+type writerFunc func(b []byte) (int, error)
+
+func (fn writerFunc) Write(b []byte) (int, error) {
+	return fn(b)
+}
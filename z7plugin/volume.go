@@ -0,0 +1,80 @@
+//go:build windows
+
+package z7plugin
+
+import (
+	"io"
+	"io/fs"
+	"unsafe"
+
+	"github.com/lxn/win"
+	"github.com/pg9182/7zplugin/winext"
+	"github.com/pg9182/7zplugin/z7"
+	"golang.org/x/sys/windows"
+)
+
+// CPP/7zip/Archive/IArchive.h
+
+const (
+	vtblIArchiveOpenVolumeCallback_GetProperty = 3
+	vtblIArchiveOpenVolumeCallback_GetStream   = 4
+)
+
+// hostVolumes wraps an IArchiveOpenVolumeCallback from the host as Volumes.
+type hostVolumes struct {
+	p       comPtr
+	abort   func() // called if the host returns E_ABORT
+	streams []*hostInStream
+}
+
+// newHostVolumes wraps p, adding a reference to it.
+func newHostVolumes(p comPtr, abort func()) *hostVolumes {
+	p.AddRef()
+	return &hostVolumes{p: p, abort: abort}
+}
+
+// Release releases the reference to the callback and the opened streams.
+func (v *hostVolumes) Release() {
+	for _, s := range v.streams {
+		s.Release()
+	}
+	v.streams = nil
+	if v.p != 0 {
+		v.p.Release()
+		v.p = 0
+	}
+}
+
+func (v *hostVolumes) Name() string {
+	var value winext.PROPVARIANT
+	if v.p.call(vtblIArchiveOpenVolumeCallback_GetProperty, uintptr(z7.PROPID_kpidName), uintptr(unsafe.Pointer(&value))) != win.S_OK {
+		return ""
+	}
+	name, _ := getProp(&value, z7.PROPID_kpidName).(string)
+	return name
+}
+
+func (v *hostVolumes) OpenVolume(name string) (r io.ReaderAt, size int64, err error) {
+	n, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	var stream uintptr
+	switch hr := v.p.call(vtblIArchiveOpenVolumeCallback_GetStream, uintptr(unsafe.Pointer(n)), uintptr(unsafe.Pointer(&stream))); hr {
+	case win.S_OK:
+		if stream == 0 {
+			return nil, 0, fs.ErrNotExist
+		}
+	case win.S_FALSE:
+		return nil, 0, fs.ErrNotExist
+	default:
+		return nil, 0, hostError(hr, v.abort)
+	}
+	s := &hostInStream{p: comPtr(stream), pos: -1, abort: v.abort}
+	if size, err = s.Size(); err != nil {
+		s.Release()
+		return nil, 0, err
+	}
+	v.streams = append(v.streams, s)
+	return s, size, nil
+}
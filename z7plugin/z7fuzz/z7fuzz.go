@@ -0,0 +1,158 @@
+// Package z7fuzz fuzzes the registered formats in-process.
+//
+// To fuzz a plugin, add a fuzz test to it which calls Fuzz, and put the seed
+// corpus in testdata/fuzz/<FuzzName> as usual:
+//
+//	func FuzzVPK(f *testing.F) {
+//		z7fuzz.Fuzz(f, "englishclient_fuzz.bsp.pak000_dir.vpk")
+//	}
+//
+// Then run it with go test -fuzz=FuzzVPK. Only the formats registered by the
+// packages linked into the test are fuzzed.
+package z7fuzz
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/pg9182/7zplugin/z7"
+	"github.com/pg9182/7zplugin/z7plugin"
+)
+
+// maxExtract is the maximum amount of data to extract from an item.
+const maxExtract = 64 << 20
+
+// Fuzz fuzzes IsArc, Open, listing the items, and test-extracting them for
+// every registered format. The data is also used with the signature of the
+// format prepended to it if it doesn't already start with it, and as the
+// contents of any other volumes opened by the format. The name is the name of
+// the archive returned by Volumes.Name.
+func Fuzz(f *testing.F, name string) {
+	arcs := z7plugin.Arcs()
+	if len(arcs) == 0 {
+		f.Fatal("no formats registered")
+	}
+	for _, arc := range arcs {
+		f.Add([]byte(signature(arc)))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, arc := range arcs {
+			if arc.IsArc != nil {
+				arc.IsArc(data)
+			}
+			if arc.CreateInArchive == nil {
+				continue
+			}
+			fuzzOpen(t, arc, name, data)
+			if sig := signature(arc); sig != "" && arc.SignatureOffset == 0 && !bytes.HasPrefix(data, []byte(sig)) {
+				fuzzOpen(t, arc, name, append([]byte(sig), data...))
+			}
+		}
+	})
+}
+
+// signature gets the first signature of a format.
+func signature(arc *z7plugin.CArcInfo) string {
+	sig := arc.Signature
+	if arc.IsMultiSignature() && sig != "" {
+		sig = sig[1:min(len(sig), 1+int(sig[0]))]
+	}
+	return sig
+}
+
+func fuzzOpen(t *testing.T, arc *z7plugin.CArcInfo, name string, data []byte) {
+	ctx := context.Background()
+
+	a := arc.CreateInArchive()
+	r := bytes.NewReader(data)
+
+	var err error
+	if av, ok := a.(z7plugin.InArchiveOpenVolumes); ok {
+		err = av.OpenVolumes(ctx, r, r.Size(), volumes{name, data}, progress{})
+	} else {
+		err = a.Open(ctx, r, r.Size(), progress{})
+	}
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err := a.Close(); err != nil {
+			t.Errorf("%s: close: %v", arc.Name, err)
+		}
+	}()
+
+	props := z7plugin.DefaultItemProps
+	if ap, ok := a.(z7plugin.InArchiveProps); ok {
+		props = ap.ItemProps()
+		for _, propID := range ap.ArcProps() {
+			ap.ArcProperty(propID)
+		}
+	}
+	n := a.NumItems()
+	if n < 0 {
+		t.Fatalf("%s: negative item count %d", arc.Name, n)
+	}
+	for i := range n {
+		it, err := a.Item(i)
+		if err != nil {
+			continue
+		}
+		for _, propID := range props {
+			it.Property(propID)
+		}
+		if it.IsDir {
+			continue
+		}
+		w := &limitWriter{n: maxExtract}
+		if err := a.Extract(ctx, i, w); err == nil && !w.exceeded && it.Props[z7.PROPID_kpidSize] == nil && uint64(w.written) != it.Size {
+			t.Errorf("%s: item %d (%q): extracted %d bytes, but size is %d", arc.Name, i, it.Path, w.written, it.Size)
+		}
+		if gs, ok := a.(z7plugin.InArchiveGetStream); ok {
+			if r, size, err := gs.GetStream(i); err == nil && r != nil {
+				io.Copy(io.Discard, io.NewSectionReader(r, 0, min(size, maxExtract)))
+			}
+		}
+	}
+}
+
+// volumes returns the same data for every volume.
+type volumes struct {
+	name string
+	data []byte
+}
+
+func (v volumes) Name() string {
+	return v.name
+}
+
+func (v volumes) OpenVolume(name string) (io.ReaderAt, int64, error) {
+	if name == "" {
+		return nil, 0, fs.ErrNotExist
+	}
+	return bytes.NewReader(v.data), int64(len(v.data)), nil
+}
+
+type progress struct{}
+
+func (progress) SetTotal(files, bytes uint64) error     { return nil }
+func (progress) SetCompleted(files, bytes uint64) error { return nil }
+
+// limitWriter discards data, failing after n bytes.
+type limitWriter struct {
+	n        int64
+	written  int64
+	exceeded bool
+}
+
+func (w *limitWriter) Write(b []byte) (int, error) {
+	if w.written+int64(len(b)) > w.n {
+		w.exceeded = true
+		return 0, errors.New("too much data extracted")
+	}
+	w.written += int64(len(b))
+	return len(b), nil
+}